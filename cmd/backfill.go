@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var (
+	atTime           string
+	backfillFrom     string
+	backfillTo       string
+	backfillInterval string
+)
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill [component-name[,component-name...]] --from <timestamp>",
+	Short: "Submit metric values for a range of past timestamps",
+	Long: `Evaluate the selected components as of every timestamp from --from to --to,
+--interval apart, and submit each value with its timestamp. Repositories are
+checked out at the last commit before each timestamp and Prometheus facts are
+evaluated at it; api facts are not time-aware and return current data.
+A backfill covers at most 10000 timestamps.
+
+Timestamps are RFC 3339 (2024-05-01T12:00:00Z) or UTC dates (2024-05-01).`,
+	Example: `  # Daily values for May after an outage
+  compass-compute backfill my-service --from 2024-05-01 --to 2024-05-31
+
+  # Hourly values of one metric for every service
+  compass-compute backfill --type SERVICE --metric error-rate --from 2024-05-01T00:00:00Z --to 2024-05-02T00:00:00Z --interval 1h`,
+	Args: computeCmd.Args,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateEnvironmentVariables("GITHUB_TOKEN", "COMPASS_API_TOKEN", "COMPASS_CLOUD_ID", "AWS_REGION")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, err := parseTimestamp(backfillFrom)
+		if err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+		to := time.Now().UTC()
+		if backfillTo != "" {
+			if to, err = parseTimestamp(backfillTo); err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+		}
+		interval, err := parseInterval(backfillInterval)
+		if err != nil {
+			return fmt.Errorf("invalid --interval: %w", err)
+		}
+		times, err := compute.Timestamps(from, to, interval)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backfilling %d timestamps from %s to %s\n", len(times), from.Format(time.RFC3339), times[len(times)-1].Format(time.RFC3339))
+
+		selector, err := componentSelector(args)
+		if err != nil {
+			return err
+		}
+		opts := runOptions()
+		opts.Metrics, err = metricFilter()
+		if err != nil {
+			return err
+		}
+		opts.DryRun = dryRun
+		opts.HistoryFile = services.CurrentConfig().HistoryFile
+		opts.BatchSize = batchSize
+		opts.SubmitRetries = submitRetries
+		opts.FailedFile = failedFile
+		return compute.Backfill(compute.Dependencies{}, selector, opts, times)
+	},
+}
+
+// parseTimestamp reads an RFC 3339 timestamp or a UTC date. Timestamps in the
+// future are rejected; there is nothing to evaluate there yet.
+func parseTimestamp(value string) (time.Time, error) {
+	var at time.Time
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", time.DateOnly} {
+		if at, err = time.ParseInLocation(layout, value, time.UTC); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not an RFC 3339 timestamp or date", value)
+	}
+	if at.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("%s is in the future", at.Format(time.RFC3339))
+	}
+	return at.UTC(), nil
+}
+
+// parseInterval reads a Go duration, or a number of days such as "7d".
+func parseInterval(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not a number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func init() {
+	backfillCmd.Flags().StringVar(&backfillFrom, "from", "", "First timestamp to evaluate (required)")
+	backfillCmd.Flags().StringVar(&backfillTo, "to", "", "Last timestamp to evaluate (default now)")
+	backfillCmd.Flags().StringVar(&backfillInterval, "interval", "1d", "Time between evaluations, e.g. 1h or 7d")
+	_ = backfillCmd.MarkFlagRequired("from")
+	backfillCmd.Flags().BoolVarP(&allComponents, "all", "a", false, "Backfill all active components")
+	backfillCmd.Flags().StringSliceVar(&componentTypes, "type", nil, "Only components of these types, e.g. SERVICE (repeatable or comma-separated)")
+	backfillCmd.Flags().StringArrayVar(&componentLabels, "label", nil, "Only components with this label; key=value matches the label key:value (repeatable, all must match)")
+	backfillCmd.Flags().StringVar(&componentOwner, "owner", "", "Only components owned by this team, by name or ID (ari:cloud:identity::team/<uuid>)")
+	backfillCmd.Flags().StringSliceVar(&excludeComponents, "exclude", nil, "Component names to skip (comma-separated)")
+	backfillCmd.Flags().StringVar(&componentsFile, "from-file", "", "File with component names, one per line (- for stdin)")
+	backfillCmd.Flags().StringSliceVar(&metricNames, "metric", nil, "Only backfill these metrics, by metadata.name (comma-separated)")
+	backfillCmd.Flags().StringArrayVar(&metricLabels, "metric-label", nil, "Only backfill metrics whose definition has this key=value label (repeatable, all must match)")
+	backfillCmd.Flags().StringVar(&componentRef, "ref", "", "Branch or tag whose history is evaluated, default branch when empty")
+	backfillCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Evaluate metrics without submitting them to Compass")
+	backfillCmd.Flags().IntVar(&batchSize, "batch-size", compute.DefaultBatchSize, "Metric values sent to Compass per request")
+	backfillCmd.Flags().IntVar(&submitRetries, "submit-retries", compute.DefaultSubmitRetries, "Retries of metric values Compass turned away without storing them")
+	backfillCmd.Flags().StringVar(&failedFile, "failed-file", compute.DefaultFailedFile, "File metric values that could not be submitted are appended to, for 'compass-compute submit'")
+}
@@ -2,15 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/recording"
+	"github.com/motain/compass-compute/internal/services"
 	"github.com/spf13/cobra"
 )
 
 var computeCmd = &cobra.Command{
-	Use:   "compute [component-name]",
+	Use:   "compute [component-name[,component-name...]]",
 	Short: "Manage compass component metrics",
 	Long: `The compute command allows you to manage metrics for a specific compass component or all components.
 
@@ -26,42 +29,222 @@ OPTIONAL ENVIRONMENT VARIABLES:
                      - Local path: /path/to/local/metrics
                      - Git repo: https://github.com/owner/repo.git/path/to/metrics
                      - Git SSH: git@github.com:owner/repo.git/path/to/metrics
-                     - GitHub tree: https://github.com/owner/repo/tree/branch/path/to/metrics`,
+                     - GitHub tree: https://github.com/owner/repo/tree/branch/path/to/metrics
+  GIT_CACHE_DIR      Directory for persistent git mirrors (same as --cache-dir)
+  GIT_BACKEND        exec, go-git, go-git-memory or archive (same as --git-backend)
+  WORKSPACE_ROOT     Directory for the per-run workspace (same as --workspace-root)
+  HISTORY_FILE       File recording every evaluated value (same as --history-file)`,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if allComponents {
-			if len(args) > 0 {
-				return fmt.Errorf("cannot specify component names when using -A/--all flag")
-			}
-			return nil
+		if len(args) > 1 {
+			return fmt.Errorf("component names must be given as one comma-separated argument")
 		}
-		if len(args) != 1 {
-			return fmt.Errorf("requires exactly one component name argument when not using -A/--all flag")
+		if allComponents && len(args) > 0 {
+			return fmt.Errorf("cannot specify component names when using -a/--all flag")
+		}
+		searching := allComponents || len(componentTypes) > 0 || len(componentLabels) > 0 || componentOwner != ""
+		if len(args) == 0 && componentsFile == "" && !searching {
+			return fmt.Errorf("requires component names, --from-file or a selector (--all, --type, --label, --owner)")
 		}
 		return nil
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		return validateEnvironmentVariables()
+		if replayDir != "" {
+			// Replayed runs talk to nothing but the recording
+			return nil
+		}
+		return validateEnvironmentVariables("GITHUB_TOKEN", "COMPASS_API_TOKEN", "COMPASS_CLOUD_ID", "AWS_REGION")
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if allComponents {
-			return compute.ProcessAll(nil, verbose, allComponents)
+		selector, err := componentSelector(args)
+		if err != nil {
+			return err
+		}
+		opts := runOptions()
+		opts.Metrics, err = metricFilter()
+		if err != nil {
+			return err
+		}
+		opts.DryRun = dryRun || replayDir != ""
+		opts.HistoryFile = services.CurrentConfig().HistoryFile
+		opts.OnlyChanged = onlyChanged
+		opts.BatchSize = batchSize
+		opts.SubmitRetries = submitRetries
+		opts.FailedFile = failedFile
+		opts.Scorecards = gradeComponents
+		if onlyChanged && opts.HistoryFile == "" {
+			return fmt.Errorf("--only-changed requires --history-file or HISTORY_FILE")
 		}
-		return compute.ProcessAll(strings.Split(args[0], ","), verbose, false)
+		if atTime != "" {
+			if opts.At, err = parseTimestamp(atTime); err != nil {
+				return fmt.Errorf("invalid --at: %w", err)
+			}
+		}
+
+		deps, err := runDependencies(opts)
+		if err != nil {
+			return err
+		}
+		return compute.ProcessAll(deps, selector, opts)
 	},
 }
 
-func validateEnvironmentVariables() error {
-	requiredEnvVars := []string{
-		"GITHUB_TOKEN",
-		"COMPASS_API_TOKEN",
-		"COMPASS_CLOUD_ID",
-		"AWS_REGION",
+// componentSelector builds the component selection from arguments and flags.
+func componentSelector(args []string) (compute.Selector, error) {
+	selector := compute.Selector{
+		All:     allComponents,
+		Exclude: excludeComponents,
+		Owner:   componentOwner,
+		Filter: services.ComponentFilter{
+			Types: componentTypes,
+		},
+	}
+
+	if len(args) > 0 {
+		selector.Names = splitNames(args[0])
+	}
+	if componentsFile != "" {
+		names, err := readComponentsFile(componentsFile)
+		if err != nil {
+			return selector, err
+		}
+		selector.Names = append(selector.Names, names...)
+	}
+
+	// Compass labels are plain strings; key=value selectors map to key:value labels
+	for _, label := range componentLabels {
+		selector.Filter.Labels = append(selector.Filter.Labels, strings.Replace(label, "=", ":", 1))
+	}
+
+	return selector, nil
+}
+
+// metricFilter builds the metric selection from --metric and --metric-label.
+func metricFilter() (compute.MetricFilter, error) {
+	filter := compute.MetricFilter{Names: metricNames}
+	for _, label := range metricLabels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return filter, fmt.Errorf("invalid --metric-label '%s', expected key=value", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[key] = value
+	}
+	return filter, nil
+}
+
+// readComponentsFile reads component names, one or more comma-separated per
+// line, from path or from stdin for "-". Blank lines and # comments are ignored.
+func readComponentsFile(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read components file: %w", err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		names = append(names, splitNames(line)...)
+	}
+	return names, nil
+}
+
+func splitNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runOptions collects the flags shared by every command that reads the catalog.
+func runOptions() compute.Options {
+	return compute.Options{
+		Verbose: verbose,
+		Ref:     componentRef,
+		Checkout: services.CheckoutOptions{
+			Backend:  gitBackend,
+			Depth:    cloneDepth,
+			Sparse:   sparseClone,
+			CacheDir: gitCacheDir,
+		},
+		WorkspaceRoot: services.CurrentConfig().WorkspaceRoot,
+		KeepWorkspace: keepWorkspace,
+	}
+}
+
+// runDependencies returns the real services, wrapped to record to --record or
+// replaced by the recording in --replay.
+func runDependencies(opts compute.Options) (compute.Dependencies, error) {
+	var store *recording.Store
+	var err error
+	switch {
+	case recordDir != "":
+		store, err = recording.NewRecorder(recordDir)
+	case replayDir != "":
+		store, err = recording.NewReplayer(replayDir)
+	default:
+		return compute.Dependencies{}, nil
+	}
+	if err != nil {
+		return compute.Dependencies{}, err
+	}
+
+	client := services.NewHTTPClient()
+	client.Transport = store.Transport(client.Transport)
+
+	var fetcher services.RepoFetcher
+	if !store.Replaying() {
+		fetcher, err = services.NewRepoFetcher(services.CurrentConfig().GitHubToken, opts.Checkout)
+		if err != nil {
+			return compute.Dependencies{}, fmt.Errorf("failed to set up repository fetcher: %w", err)
+		}
+		fmt.Printf("Recording run to %s\n", recordDir)
+	} else {
+		fmt.Printf("Replaying run from %s\n", replayDir)
+	}
+
+	return compute.Dependencies{
+		Compass:    services.NewCompassServiceWithClient(client),
+		Fetcher:    store.Fetcher(fetcher),
+		Prometheus: store.Prometheus(services.NewLazyPrometheusService()),
+		HTTPClient: client,
+	}, nil
+}
+
+// validateCatalogEnvironment checks the given settings and, unless METRIC_DIR
+// is a local directory, the GitHub token the catalog is fetched with.
+func validateCatalogEnvironment(names ...string) error {
+	if !services.LocalMetricDir() {
+		names = append([]string{"GITHUB_TOKEN"}, names...)
+	}
+	return validateEnvironmentVariables(names...)
+}
+
+// validateEnvironmentVariables checks that the settings behind the given
+// environment variables are present in the effective configuration.
+func validateEnvironmentVariables(names ...string) error {
+	cfg := services.CurrentConfig()
+	values := map[string]string{
+		"GITHUB_TOKEN":      cfg.GitHubToken,
+		"COMPASS_API_TOKEN": cfg.CompassAPIToken,
+		"COMPASS_CLOUD_ID":  cfg.CompassCloudID,
+		"AWS_REGION":        cfg.AWSRegion,
 	}
 
 	var missing []string
-	for _, envVar := range requiredEnvVars {
-		if os.Getenv(envVar) == "" {
-			missing = append(missing, envVar)
+	for _, name := range names {
+		if values[name] == "" {
+			missing = append(missing, name)
 		}
 	}
 
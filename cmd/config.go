@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the compass-compute configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration with secrets masked",
+	Long: `Print the configuration compass-compute would run with, after merging defaults,
+the config file, environment variables and flags. Tokens are masked.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		masked := services.CurrentConfig().Masked()
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(masked); err != nil {
+			return fmt.Errorf("failed to render config: %w", err)
+		}
+		return encoder.Close()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+}
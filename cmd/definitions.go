@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/definitions"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyDefinitions bool
+	pruneSources     bool
+)
+
+var definitionsCmd = &cobra.Command{
+	Use:   "definitions",
+	Short: "Manage Compass metric definitions",
+}
+
+var definitionsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Create and update Compass metric definitions from the metric YAML",
+	Long: `Compare the metric definitions in the catalog (or METRIC_DIR) with those in
+Compass and print the plan. Definitions are matched by metadata.name; the
+description and unit are taken from spec.description and spec.format.unit.
+
+Nothing is changed unless --apply is passed. Definitions that only exist in
+Compass are reported but never deleted.`,
+	Example: `  # Show what would change
+  compass-compute definitions sync
+
+  # Create and update the definitions
+  compass-compute definitions sync --apply`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateCatalogEnvironment("COMPASS_API_TOKEN", "COMPASS_CLOUD_ID")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		compass := services.NewCompassService()
+
+		run, err := compute.NewRun(compute.Dependencies{Compass: compass}, runOptions())
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := run.Close(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}()
+
+		current, err := compass.GetMetricDefinitions()
+		if err != nil {
+			return fmt.Errorf("failed to get metric definitions from Compass: %w", err)
+		}
+
+		changes, err := definitions.Plan(run.Metrics().Definitions(), current)
+		if err != nil {
+			return err
+		}
+		definitions.PrintPlan(os.Stdout, changes, verbose)
+
+		if !definitions.Pending(changes) {
+			fmt.Println("Compass is up to date")
+			return nil
+		}
+		if !applyDefinitions {
+			fmt.Println("Run with --apply to make these changes")
+			return nil
+		}
+		return definitions.Apply(compass, changes)
+	},
+}
+
+var definitionsAttachCmd = &cobra.Command{
+	Use:   "attach",
+	Short: "Attach catalog metrics to the components they apply to",
+	Long: `Make sure every component whose type is listed in a metric's componentType has
+a metric source for that metric, so compute evaluates it. Definitions must
+already exist in Compass (see 'definitions sync').
+
+Nothing is changed unless --apply is passed. With --prune, sources of catalog
+metrics are also removed from components whose type is no longer listed.
+Metrics that are not in the catalog are never touched.`,
+	Example: `  # Show which sources are missing
+  compass-compute definitions attach
+
+  # Create missing sources and remove obsolete ones
+  compass-compute definitions attach --prune --apply`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateCatalogEnvironment("COMPASS_API_TOKEN", "COMPASS_CLOUD_ID")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		compass := services.NewCompassService()
+
+		run, err := compute.NewRun(compute.Dependencies{Compass: compass}, runOptions())
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := run.Close(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}()
+
+		current, err := compass.GetMetricDefinitions()
+		if err != nil {
+			return fmt.Errorf("failed to get metric definitions from Compass: %w", err)
+		}
+		components, err := compass.ListComponents(services.ComponentFilter{})
+		if err != nil {
+			return fmt.Errorf("failed to list components: %w", err)
+		}
+		if verbose {
+			fmt.Printf("Found %d components and %d metric definitions in Compass\n", len(components), len(current))
+		}
+
+		plan := definitions.PlanSources(run.Metrics().Definitions(), current, components, pruneSources)
+		definitions.PrintSourcePlan(os.Stdout, plan)
+
+		if len(plan.Changes) == 0 {
+			fmt.Println("All components have the metric sources they need")
+			return nil
+		}
+		if !applyDefinitions {
+			fmt.Println("Run with --apply to make these changes")
+			return nil
+		}
+		return definitions.ApplySources(compass, plan.Changes)
+	},
+}
+
+func init() {
+	definitionsCmd.PersistentFlags().BoolVar(&applyDefinitions, "apply", false, "Make the changes in Compass instead of only printing the plan")
+	definitionsAttachCmd.Flags().BoolVar(&pruneSources, "prune", false, "Also remove sources of catalog metrics from components whose type is no longer listed")
+	definitionsCmd.AddCommand(definitionsSyncCmd)
+	definitionsCmd.AddCommand(definitionsAttachCmd)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/spf13/cobra"
+)
+
+var failOnRegression bool
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <component-name>",
+	Short: "Compare locally evaluated metric values with those in Compass",
+	Long: `Evaluate the metrics of a component without submitting them and show each
+value next to the latest one stored in Compass. Values that moved against the
+metric's spec.direction (higher-is-better unless set to lower-is-better) are
+marked as REGRESSION.`,
+	Example: `  # Preview the effect of a pull request on the scores
+  compass-compute diff my-service --ref refs/pull/42/head
+
+  # Fail CI when a metric gets worse
+  compass-compute diff my-service --fail-on-regression`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateEnvironmentVariables("GITHUB_TOKEN", "COMPASS_API_TOKEN", "COMPASS_CLOUD_ID", "AWS_REGION")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := runOptions()
+		var err error
+		opts.Metrics, err = metricFilter()
+		if err != nil {
+			return err
+		}
+
+		run, err := compute.NewRun(compute.Dependencies{}, opts)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := run.Close(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}()
+
+		evaluation, err := run.Evaluate(args[0])
+		if err != nil {
+			return err
+		}
+		if len(evaluation.Results) == 0 {
+			fmt.Printf("No metrics to compute for component '%s'\n", args[0])
+			return nil
+		}
+
+		diffs := compute.Diff(evaluation)
+		fmt.Printf("Component '%s' at commit %s\n", evaluation.Component.Name, evaluation.Commit)
+		if err := compute.PrintDiff(os.Stdout, diffs); err != nil {
+			return err
+		}
+
+		if regressions := compute.Regressions(diffs); regressions > 0 && failOnRegression {
+			return fmt.Errorf("%d metrics regressed", regressions)
+		}
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&componentRef, "ref", "", "Branch, tag, commit SHA or PR ref (e.g. refs/pull/42/head) of the component repository")
+	diffCmd.Flags().StringSliceVar(&metricNames, "metric", nil, "Only compare these metrics, by metadata.name (comma-separated)")
+	diffCmd.Flags().StringArrayVar(&metricLabels, "metric-label", nil, "Only compare metrics whose definition has this key=value label (repeatable, all must match)")
+	diffCmd.Flags().BoolVar(&failOnRegression, "fail-on-regression", false, "Exit with an error when any metric regressed")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/motain/compass-compute/internal/history"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var historyMetric string
+
+var historyCmd = &cobra.Command{
+	Use:   "history <component-name>",
+	Short: "Show the recorded metric values of a component",
+	Long: `Print the metric values compute recorded for a component in the history file
+(--history-file or HISTORY_FILE), oldest first. Values skipped by
+--only-changed are listed as not submitted.`,
+	Example: `  compass-compute history my-service --history-file metrics-history.jsonl
+  compass-compute history my-service --metric test-coverage`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := services.CurrentConfig().HistoryFile
+		if path == "" {
+			return fmt.Errorf("no history file configured, set --history-file or HISTORY_FILE")
+		}
+		store, err := history.Open(path)
+		if err != nil {
+			return err
+		}
+
+		records := store.Records(args[0], historyMetric)
+		if len(records) == 0 {
+			fmt.Printf("No history for component '%s'\n", args[0])
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIMESTAMP\tMETRIC\tVALUE\tLEVEL\tSUBMITTED\tCOMMIT\tDEFINITION")
+		for _, record := range records {
+			level := record.Level
+			if level == "" {
+				level = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+				record.Timestamp.Format(time.RFC3339), record.Metric, record.Value, level,
+				record.Submitted, shortSHA(record.Commit), record.DefinitionHash)
+		}
+		return w.Flush()
+	},
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyMetric, "metric", "", "Only show this metric")
+}
@@ -1,41 +1,163 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/services"
 	"github.com/spf13/cobra"
 )
 
 var (
 	verbose       bool
 	allComponents bool
+	cloneDepth    int
+	sparseClone   bool
+	gitCacheDir   string
+	gitBackend    string
+	componentRef  string
+	workspaceRoot string
+	keepWorkspace bool
+	strictMetrics bool
+
+	componentTypes    []string
+	componentLabels   []string
+	componentOwner    string
+	excludeComponents []string
+	componentsFile    string
+	metricNames       []string
+	metricLabels      []string
+
+	dryRun        bool
+	recordDir     string
+	replayDir     string
+	historyFile   string
+	onlyChanged   bool
+	batchSize     int
+	submitRetries int
+	failedFile    string
+
+	configFile        string
+	githubOrg         string
+	compassBaseURL    string
+	serviceSlugPrefix string
+	catalogRepo       string
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.SetOutput(services.RedactingWriter{W: os.Stderr})
+
+	// Errors may embed URLs or command output, so cobra must not print them unredacted
+	rootCmd.SilenceErrors = true
 	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", services.Redact(err.Error()))
 		os.Exit(1)
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to a YAML config file (env COMPASS_COMPUTE_CONFIG, default ./"+services.DefaultConfigFile+" if present)")
+	rootCmd.PersistentFlags().StringVar(&githubOrg, "github-org", "", "GitHub organisation of component and catalog repositories (env GITHUB_ORG)")
+	rootCmd.PersistentFlags().StringVar(&compassBaseURL, "compass-base-url", "", "Atlassian gateway API base URL (env COMPASS_BASE_URL)")
+	rootCmd.PersistentFlags().StringVar(&serviceSlugPrefix, "slug-prefix", "", "Prefix prepended to component names to form Compass slugs (env SERVICE_SLUG_PREFIX)")
+	rootCmd.PersistentFlags().StringVar(&catalogRepo, "catalog-repo", "", "Repository holding the metric definitions (env CATALOG_REPO)")
+	rootCmd.PersistentFlags().IntVar(&cloneDepth, "depth", 1, "History depth of repository clones (0 for full history)")
+	rootCmd.PersistentFlags().BoolVar(&sparseClone, "sparse", true, "Only check out the paths referenced by metric facts")
+	rootCmd.PersistentFlags().StringVar(&gitBackend, "git-backend", envOr("GIT_BACKEND", services.BackendExec), "How repositories are fetched: exec (git binary), go-git, go-git-memory or archive (env GIT_BACKEND)")
+	rootCmd.PersistentFlags().StringVar(&workspaceRoot, "workspace-root", "", "Directory in which the per-run workspace is created (env WORKSPACE_ROOT, default system temp dir)")
+	rootCmd.PersistentFlags().BoolVar(&keepWorkspace, "keep-workspace", false, "Keep the run workspace with all checkouts instead of removing it at exit")
+	rootCmd.PersistentFlags().BoolVar(&strictMetrics, "strict", false, "Reject metric definitions with fields unknown to their apiVersion (env METRIC_STRICT)")
+	rootCmd.PersistentFlags().StringVar(&gitCacheDir, "cache-dir", os.Getenv("GIT_CACHE_DIR"), "Directory for persistent git mirrors reused across runs (env GIT_CACHE_DIR)")
+	rootCmd.PersistentFlags().StringVar(&historyFile, "history-file", "", "JSON-lines file recording every evaluated metric value (env HISTORY_FILE)")
 	rootCmd.AddCommand(computeCmd)
-	computeCmd.PersistentFlags().BoolVarP(&allComponents, "all", "a", false, "Compute metrics for all components (when implemented)")
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(definitionsCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(submitCmd)
+	rootCmd.AddCommand(backfillCmd)
+	rootCmd.AddCommand(scorecardsCmd)
+	computeCmd.PersistentFlags().BoolVarP(&allComponents, "all", "a", false, "Compute metrics for all active components")
+	computeCmd.PersistentFlags().StringSliceVar(&componentTypes, "type", nil, "Only components of these types, e.g. SERVICE (repeatable or comma-separated)")
+	computeCmd.PersistentFlags().StringArrayVar(&componentLabels, "label", nil, "Only components with this label; key=value matches the label key:value (repeatable, all must match)")
+	computeCmd.PersistentFlags().StringVar(&componentOwner, "owner", "", "Only components owned by this team, by name or ID (ari:cloud:identity::team/<uuid>)")
+	computeCmd.PersistentFlags().StringSliceVar(&excludeComponents, "exclude", nil, "Component names to skip (comma-separated)")
+	computeCmd.PersistentFlags().StringSliceVar(&metricNames, "metric", nil, "Only compute these metrics, by metadata.name (comma-separated)")
+	computeCmd.PersistentFlags().StringArrayVar(&metricLabels, "metric-label", nil, "Only compute metrics whose definition has this key=value label (repeatable, all must match)")
+	computeCmd.PersistentFlags().StringVar(&componentsFile, "from-file", "", "File with component names, one per line (- for stdin)")
+	computeCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Evaluate metrics without submitting them to Compass")
+	computeCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Record every API, Prometheus, Compass and repository response of the run to this directory")
+	computeCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Replay a recorded run from this directory without network access (implies --dry-run)")
+	computeCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	computeCmd.PersistentFlags().IntVar(&batchSize, "batch-size", compute.DefaultBatchSize, "Metric values sent to Compass per request")
+	computeCmd.PersistentFlags().IntVar(&submitRetries, "submit-retries", compute.DefaultSubmitRetries, "Retries of metric values Compass turned away without storing them")
+	computeCmd.PersistentFlags().StringVar(&failedFile, "failed-file", compute.DefaultFailedFile, "File metric values that could not be submitted are appended to, for 'compass-compute submit'")
+	computeCmd.PersistentFlags().BoolVar(&onlyChanged, "only-changed", false, "Only submit values that differ from the last submission up to their timestamp in the history file")
+	computeCmd.PersistentFlags().BoolVar(&gradeComponents, "scorecards", false, "Grade the components on the scorecards of the metric directory after evaluating them")
+	computeCmd.PersistentFlags().StringVar(&atTime, "at", "", "Evaluate as of this past RFC 3339 timestamp or date and submit the values with it")
+	computeCmd.PersistentFlags().StringVar(&componentRef, "ref", "", "Branch, tag, commit SHA or PR ref (e.g. refs/pull/42/head) of the component repository")
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// loadConfig assembles the run configuration once, before any command runs.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	cfg, err := services.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	for name, field := range map[string]*string{
+		"github-org":       &cfg.GitHubOrg,
+		"compass-base-url": &cfg.CompassBaseURL,
+		"slug-prefix":      &cfg.ServiceSlugPrefix,
+		"catalog-repo":     &cfg.CatalogRepo,
+		"workspace-root":   &cfg.WorkspaceRoot,
+		"history-file":     &cfg.HistoryFile,
+	} {
+		if flags.Changed(name) {
+			*field, _ = flags.GetString(name)
+		}
+	}
+	if flags.Changed("strict") {
+		cfg.StrictMetrics = strictMetrics
+	}
+
+	services.SetConfig(cfg)
+	return nil
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "compass-compute <component-name>",
-	Short: "A tool to compute compass component metrics",
+	Use:               "compass-compute <component-name>",
+	Short:             "A tool to compute compass component metrics",
+	PersistentPreRunE: loadConfig,
 	Long: `compass-compute is a CLI tool for computing compass component metrics.
 
 ENVIRONMENT VARIABLES:
   GITHUB_TOKEN       GitHub personal access token for repository access
+  GITHUB_ENTERPRISE_TOKEN (Optional) Token for GitHub Enterprise hosts found in
+                     component repository links (defaults to GITHUB_TOKEN)
   COMPASS_API_TOKEN  Compass API authentication token
   COMPASS_CLOUD_ID   Compass cloud instance identifier
   AWS_REGION         AWS region for cloud resources (e.g., us-east-1)
   AWS_ROLE           AWS IAM role ARN for authentication
+  WORKSPACE_ROOT     (Optional) Directory in which each run creates its temporary
+                     workspace (defaults to the system temp dir)
+  COMPASS_COMPUTE_CONFIG (Optional) Path to a YAML config file; settings such as
+                     githubOrg, compassBaseUrl, serviceSlugPrefix and catalogRepo
+                     can be set there and overridden by env or flags
   METRIC_DIR         (Optional) Override metric directory source:
                      - Local path: /path/to/local/metrics
                      - Git repo: https://github.com/owner/repo.git/path/to/metrics
@@ -51,10 +173,33 @@ All environment variables except METRIC_DIR are required for proper operation.`,
   
   # Enable verbose output
   compass-compute compute my-component --verbose
+
+  # Evaluate a component at a specific branch or pull request
+  compass-compute compute my-component --ref feature/new-ci
+  compass-compute compute my-component --ref refs/pull/42/head
+
+  # Keep the checkouts around for inspection
+  compass-compute compute my-component --keep-workspace --verbose
   
-  # Compute metrics for all components (when implemented)
-  compass-compute compute -A
-  compass-compute compute -A --verbose
+  # Compute metrics for all components
+  compass-compute compute --all
+  compass-compute compute --all --exclude legacy-service
+
+  # Recompute a subset after changing a metric
+  compass-compute compute --type SERVICE --label tier=1
+  compass-compute compute --owner team-x
+  compass-compute compute --owner ari:cloud:identity::team/<uuid>
+  compass-compute compute --from-file components.txt
+
+  # Submit the values a component had at a past time
+  compass-compute compute my-component --at 2024-05-01T12:00:00Z
+
+  # Grade the components on the scorecards, without submitting
+  compass-compute compute --type SERVICE --scorecards --dry-run
+
+  # Only recompute the metrics you changed
+  compass-compute compute --all --metric test-coverage,deployment-frequency
+  compass-compute compute --type SERVICE --metric-label team=platform
   
   # Set required environment variables
   export GITHUB_TOKEN="your-github-token"
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var schemaVersions []string
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of metric definitions",
+	Long: `Print a JSON Schema (draft 2020-12) describing metric definition YAML, for
+editor validation and autocompletion. By default the schema accepts every
+supported apiVersion; use --version to restrict it.
+
+Example for the YAML language server:
+
+  compass-compute schema > metric.schema.json
+  # yaml-language-server: $schema=./metric.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := services.MetricJSONSchema(schemaVersions...)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(schema); err != nil {
+			return fmt.Errorf("failed to render schema: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	schemaCmd.Flags().StringSliceVar(&schemaVersions, "version", nil, "Schema versions to include (v1, v2); all when omitted")
+}
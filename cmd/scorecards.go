@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/scorecard"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyScorecards bool
+	gradeComponents bool
+)
+
+var scorecardsCmd = &cobra.Command{
+	Use:   "scorecards",
+	Short: "Manage scorecards defined next to the metric YAML",
+}
+
+var scorecardsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Create and update Compass scorecards from the Scorecard YAML",
+	Long: `Compare the documents of kind Scorecard in the catalog (or METRIC_DIR) with
+the scorecards in Compass and print the plan. Scorecards are matched by
+metadata.name and their criteria by name. Criteria refer to metric
+definitions, which must exist in Compass already (see 'definitions sync'),
+and their weights must add up to 100.
+
+Grades are only used locally ('compute --scorecards'); Compass shows the score.
+Nothing is changed unless --apply is passed. Scorecards that only exist in
+Compass are reported but never deleted.`,
+	Example: `  # Show what would change
+  compass-compute scorecards sync
+
+  # Create and update the scorecards
+  compass-compute scorecards sync --apply`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateCatalogEnvironment("COMPASS_API_TOKEN", "COMPASS_CLOUD_ID")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		compass := services.NewCompassService()
+
+		run, err := compute.NewRun(compute.Dependencies{Compass: compass}, runOptions())
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := run.Close(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}()
+
+		scorecards, err := compute.LoadScorecards(run.MetricPath(), run.Metrics())
+		if err != nil {
+			return err
+		}
+		metricDefinitions, err := compass.GetMetricDefinitions()
+		if err != nil {
+			return fmt.Errorf("failed to get metric definitions from Compass: %w", err)
+		}
+		current, err := compass.GetScorecards()
+		if err != nil {
+			return fmt.Errorf("failed to get scorecards from Compass: %w", err)
+		}
+
+		changes, err := scorecard.Plan(scorecards, metricDefinitions, current)
+		if err != nil {
+			return err
+		}
+		scorecard.PrintPlan(os.Stdout, changes, verbose)
+
+		if !scorecard.Pending(changes) {
+			fmt.Println("Compass is up to date")
+			return nil
+		}
+		if !applyScorecards {
+			fmt.Println("Run with --apply to make these changes")
+			return nil
+		}
+		return scorecard.Apply(compass, changes)
+	},
+}
+
+func init() {
+	scorecardsSyncCmd.Flags().BoolVar(&applyScorecards, "apply", false, "Make the changes in Compass instead of only printing the plan")
+	scorecardsCmd.AddCommand(scorecardsSyncCmd)
+}
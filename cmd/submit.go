@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var submitCmd = &cobra.Command{
+	Use:   "submit <failed-file>",
+	Short: "Resend metric values that compute could not submit",
+	Long: `Send the metric values saved by compute after Compass failed to store them
+(see --failed-file), without evaluating the metrics again. Values keep the
+timestamp of their original evaluation. The file is removed once every value
+is stored; values that fail again are written back to it.`,
+	Example: `  compass-compute submit compass-compute-failed.jsonl`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateEnvironmentVariables("COMPASS_API_TOKEN", "COMPASS_CLOUD_ID")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+		submissions, err := compute.LoadSubmissions(path)
+		if err != nil {
+			return err
+		}
+
+		queue := compute.NewSubmitQueue(services.NewCompassService(), batchSize, submitRetries, verbose)
+		for _, submission := range submissions {
+			submission.Error = ""
+			queue.Add(submission)
+		}
+		queue.Flush()
+
+		stored, requests := queue.Stored()
+		fmt.Printf("Submitted %d of %d metric values in %d requests\n", stored, len(submissions), requests)
+		if len(queue.Failed()) > 0 {
+			return compute.ReportFailed(queue.Failed(), path, true)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	submitCmd.Flags().IntVar(&batchSize, "batch-size", compute.DefaultBatchSize, "Metric values sent to Compass per request")
+	submitCmd.Flags().IntVar(&submitRetries, "submit-retries", compute.DefaultSubmitRetries, "Retries of metric values Compass turned away without storing them")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motain/compass-compute/internal/compute"
+	"github.com/motain/compass-compute/internal/metrictest"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [metric-dir]",
+	Short: "Run the golden-file tests of the metric definitions",
+	Long: `Evaluate metric definitions against the *.test.yaml fixtures found next to
+them and report pass/fail per fixture. Fixtures declare a fake repository
+tree, canned API and Prometheus responses and the expected value, so no
+repository, Compass or Prometheus access is needed.
+
+With a directory argument, definitions and fixtures are read from it;
+otherwise the catalog (or METRIC_DIR) is fetched as for compute. The command
+fails when any fixture fails.`,
+	Example: `  # Test a local checkout of the catalog
+  compass-compute test ./of-catalog/config/grading-system
+
+  # Test the configured catalog
+  compass-compute test`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var metricPath string
+		var registry *services.MetricRegistry
+		if len(args) == 1 {
+			metricPath = args[0]
+			var err error
+			registry, err = services.LoadMetricRegistry(metricPath, services.MetricParseOptions{Strict: services.CurrentConfig().StrictMetrics})
+			if err != nil {
+				return err
+			}
+		} else {
+			if err := validateCatalogEnvironment(); err != nil {
+				return err
+			}
+			run, err := compute.NewRun(compute.Dependencies{}, runOptions())
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := run.Close(); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}()
+			metricPath = run.MetricPath()
+			registry = run.Metrics()
+		}
+
+		fixtures, err := metrictest.LoadFixtures(metricPath)
+		if err != nil {
+			return err
+		}
+		if len(fixtures) == 0 {
+			fmt.Printf("No %s fixtures found in %s\n", metrictest.FixtureSuffix, metricPath)
+			return nil
+		}
+
+		results := metrictest.Run(registry, fixtures)
+		if failed := metrictest.PrintResults(os.Stdout, results, verbose); failed > 0 {
+			return fmt.Errorf("%d of %d metric tests failed", failed, len(results))
+		}
+		return nil
+	},
+}
@@ -1,118 +1,659 @@
 package compute
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/motain/compass-compute/internal/facts"
+	"github.com/motain/compass-compute/internal/history"
+	"github.com/motain/compass-compute/internal/scorecard"
 	"github.com/motain/compass-compute/internal/services"
 )
 
-func Process(componentName string, verbose bool, compass *services.CompassService) error {
-	if verbose {
-		fmt.Printf("Starting compass-compute with component: %s\n", componentName)
+// Options carries the run-wide settings of a compute invocation.
+type Options struct {
+	Verbose       bool
+	Ref           string // branch, tag, commit or PR ref of the component repository
+	Checkout      services.CheckoutOptions
+	WorkspaceRoot string // parent of the run workspace, system temp dir when empty
+	KeepWorkspace bool
+	Metrics       MetricFilter
+	DryRun        bool // evaluate metrics without submitting them
+	HistoryFile   string
+	OnlyChanged   bool   // skip submissions whose value matches the one submitted last before their timestamp
+	BatchSize     int    // metric values per Compass request, DefaultBatchSize when zero
+	SubmitRetries int    // retries of values Compass turned away without storing them
+	FailedFile    string // where values that could not be stored are saved, DefaultFailedFile when empty
+	// At evaluates components as of a past time and submits the values with
+	// that timestamp; now when zero
+	At time.Time
+	// Scorecards grades components on the scorecards of the metric directory
+	Scorecards bool
+}
+
+// componentsDir is the workspace directory component checkouts are made in.
+const componentsDir = "components"
+
+// Dependencies are the external systems a run talks to. Nil fields are
+// replaced by the real implementations for the current configuration.
+type Dependencies struct {
+	Compass    services.CompassClient
+	Fetcher    services.RepoFetcher
+	Prometheus services.PrometheusServiceInterface
+	HTTPClient *http.Client // used by api facts
+}
+
+// Run holds the state shared by every component processed in one invocation:
+// the workspace, the repository fetcher and the loaded metric definitions.
+type Run struct {
+	opts       Options
+	deps       Dependencies
+	compass    services.CompassClient
+	fetcher    services.RepoFetcher
+	workspace  *services.Workspace
+	metricPath string
+	metrics    *services.MetricRegistry
+	history    *history.Store
+	queue      *SubmitQueue
+	scorecards []scorecard.Scorecard
+	grades     []scorecard.Result
+}
+
+// NewRun creates the run workspace and fetches the metric definitions into it.
+// Callers must Close the run to remove the workspace.
+func NewRun(deps Dependencies, opts Options) (*Run, error) {
+	verbose := opts.Verbose
+	cfg := services.CurrentConfig()
+
+	if deps.Compass == nil {
+		deps.Compass = services.NewCompassService()
+	}
+	if deps.Fetcher == nil {
+		fetcher, err := services.NewRepoFetcher(cfg.GitHubToken, opts.Checkout)
+		switch {
+		case err != nil && services.LocalMetricDir():
+			// Definitions are read from disk; only component checkouts need git
+			deps.Fetcher = unavailableFetcher{err: err}
+		case err != nil:
+			return nil, fmt.Errorf("failed to set up repository fetcher: %w", err)
+		default:
+			deps.Fetcher = fetcher
+		}
+	}
+	if deps.Prometheus == nil {
+		// Shared by every evaluation; connects on the first prometheus fact
+		deps.Prometheus = services.NewLazyPrometheusService()
 	}
 
-	component, err := compass.GetComponent(componentName)
+	workspace, err := services.NewWorkspace(opts.WorkspaceRoot, opts.KeepWorkspace)
 	if err != nil {
-		return fmt.Errorf("failed to get component '%s': %w", componentName, err)
+		return nil, err
 	}
-
 	if verbose {
-		fmt.Printf("Found component '%s' (ID: %s, Type: %s) with %d metrics\n",
-			component.Name, component.ID, component.Type, len(component.Metrics))
+		fmt.Printf("Using workspace: %s\n", workspace.Root)
+	}
+
+	run := &Run{opts: opts, deps: deps, compass: deps.Compass, fetcher: deps.Fetcher, workspace: workspace}
+	run.queue = NewSubmitQueue(deps.Compass, opts.BatchSize, opts.SubmitRetries, verbose)
+	run.queue.onStored = func(submission Submission) {
+		if submission.record != nil {
+			record := *submission.record
+			record.Submitted = true
+			run.recordHistory(record)
+		}
 	}
+	if opts.HistoryFile != "" {
+		if run.history, err = history.Open(opts.HistoryFile); err != nil {
+			_ = run.Close()
+			return nil, err
+		}
+	}
+	if err := run.setupMetrics(cfg); err != nil {
+		_ = run.Close()
+		return nil, err
+	}
+	if err := opts.Metrics.validate(run.metrics); err != nil {
+		_ = run.Close()
+		return nil, err
+	}
+	return run, nil
+}
 
-	cloner := services.NewGitHubCloner(os.Getenv("GITHUB_TOKEN"))
+// unavailableFetcher fails every checkout with the error that kept the real
+// fetcher from being set up.
+type unavailableFetcher struct {
+	err error
+}
 
-	skipCatalogRepo, err := cloner.SetupMetricDirectory(verbose)
+func (f unavailableFetcher) Clone(info *services.GitInfo, repoPath string, paths []string) (string, error) {
+	return "", fmt.Errorf("failed to set up repository fetcher: %w", f.err)
+}
+
+func (r *Run) setupMetrics(cfg *services.Config) error {
+	verbose := r.opts.Verbose
+
+	skipCatalogRepo, err := services.SetupMetricDirectory(r.fetcher, r.workspace.Root, verbose)
 	if err != nil {
 		return fmt.Errorf("failed to setup metric directory: %w", err)
 	}
 
-	repos := []string{componentName}
 	if !skipCatalogRepo {
-		repos = append(repos, services.CatalogRepo)
+		catalog := &services.GitInfo{Host: services.DefaultGitHost, Owner: cfg.GitHubOrg, Repo: cfg.CatalogRepo}
+		catalogCommit, err := r.fetcher.Clone(catalog, r.workspace.Path(cfg.CatalogRepo), []string{cfg.MetricPath})
+		if err != nil {
+			return fmt.Errorf("failed to clone repository '%s': %w", cfg.CatalogRepo, err)
+		}
+		if verbose {
+			fmt.Printf("Successfully cloned repository: %s at commit %s\n", cfg.CatalogRepo, catalogCommit)
+		}
+	}
+
+	r.metricPath = services.GetMetricLocalPath(r.workspace.Root)
+	if _, err := os.Stat(r.metricPath); os.IsNotExist(err) {
+		return fmt.Errorf("metric directory not found at: %s", r.metricPath)
+	}
+
+	if verbose {
+		fmt.Printf("Using metric directory: %s\n", r.metricPath)
 	}
 
-	for _, repo := range repos {
-		if err := cloner.Clone(services.GitHubOrg, repo, services.LocalBasePath); err != nil {
-			return fmt.Errorf("failed to clone repository '%s': %w", repo, err)
+	r.metrics, err = services.LoadMetricRegistry(r.metricPath, services.MetricParseOptions{Strict: cfg.StrictMetrics})
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Loaded %d metric definitions\n", len(r.metrics.Definitions()))
+	}
+
+	if r.opts.Scorecards {
+		if r.scorecards, err = LoadScorecards(r.metricPath, r.metrics); err != nil {
+			return err
 		}
 		if verbose {
-			fmt.Printf("Successfully cloned repository: %s\n", repo)
+			fmt.Printf("Loaded %d scorecards\n", len(r.scorecards))
 		}
 	}
+	return nil
+}
+
+// LoadScorecards reads and validates the scorecards of a metric directory.
+func LoadScorecards(metricPath string, metrics *services.MetricRegistry) ([]scorecard.Scorecard, error) {
+	scorecards, err := scorecard.Load(metricPath)
+	if err == nil {
+		err = scorecard.Validate(scorecards, metrics)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid scorecards:\n%w", err)
+	}
+	if len(scorecards) == 0 {
+		return nil, fmt.Errorf("no scorecards found in %s", metricPath)
+	}
+	return scorecards, nil
+}
+
+// Grades returns the scorecard results of the components processed so far.
+func (r *Run) Grades() []scorecard.Result {
+	return r.grades
+}
+
+// Metrics returns the metric definitions loaded for this run.
+func (r *Run) Metrics() *services.MetricRegistry {
+	return r.metrics
+}
+
+// MetricPath returns the directory the metric definitions were loaded from.
+func (r *Run) MetricPath() string {
+	return r.metricPath
+}
 
-	metricPath := services.GetMetricLocalPath()
-	if _, err := os.Stat(metricPath); os.IsNotExist(err) {
-		return fmt.Errorf("metric directory not found at: %s", metricPath)
+// Close removes the workspace unless it is to be kept.
+func (r *Run) Close() error {
+	if r.opts.KeepWorkspace {
+		fmt.Printf("Keeping workspace: %s\n", r.workspace.Root)
 	}
+	return r.workspace.Cleanup()
+}
+
+// MetricResult is the locally evaluated value of one metric of a component.
+type MetricResult struct {
+	Metric     services.Metric
+	Definition *services.MetricDefinition
+	Value      string // as submitted to Compass
+	Level      string // level of the value when the definition has thresholds
+	Rank       int    // rank of Level, submitted to the level metric
+	Err        error  // evaluation error, Value is empty
+}
 
+// Evaluation holds the metric values of a component at one commit.
+type Evaluation struct {
+	Component *services.Component
+	Commit    string
+	Results   []MetricResult
+}
+
+// Evaluate checks out the component and evaluates its metrics without
+// submitting them. Metrics that fail to evaluate are reported in their result.
+func (r *Run) Evaluate(componentName string) (*Evaluation, error) {
+	verbose := r.opts.Verbose
 	if verbose {
-		fmt.Printf("Using metric directory: %s\n", metricPath)
+		fmt.Printf("Starting compass-compute with component: %s\n", componentName)
 	}
 
-	// Process metrics
-	processed := 0
+	component, err := r.compass.GetComponent(componentName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get component '%s': %w", componentName, err)
+	}
+	evaluation := &Evaluation{Component: component}
+
+	if verbose {
+		fmt.Printf("Found component '%s' (ID: %s, Type: %s) with %d metrics\n",
+			component.Name, component.ID, component.Type, len(component.Metrics))
+	}
+
+	// Level metrics are submitted along with the metric they grade, not evaluated
+	levelMetrics := make(map[string]bool)
 	for _, metric := range component.Metrics {
-		if verbose {
-			fmt.Printf("Processing metric: %s\n", metric.Name)
+		if definition, ok := r.metrics.Lookup(metric.Name, component.Type); ok && definition.Spec.LevelMetric() != "" {
+			levelMetrics[definition.Spec.LevelMetric()] = true
 		}
+	}
 
-		metricFacts, err := compass.GetMetricFacts(metric.Name, component.Type)
+	// Resolve facts up front so the component checkout only needs the files they read
+	metricFacts := make(map[string][]services.Fact)
+	var sparsePaths []string
+	fullCheckout := false
+	for _, metric := range component.Metrics {
+		if levelMetrics[metric.Name] || !r.opts.Metrics.matches(r.metrics, metric.Name, component.Type) {
+			continue
+		}
+
+		factList, err := r.metrics.Facts(metric.Name, component.Type)
 		if err != nil {
 			if verbose {
 				fmt.Printf("Warning: failed to get metric facts for '%s': %v\n", metric.Name, err)
 			}
 			continue
 		}
+		metricFacts[metric.Name] = factList
+
+		paths, full := facts.RepoPaths(factList, componentName, componentName)
+		sparsePaths = append(sparsePaths, paths...)
+		fullCheckout = fullCheckout || full
+	}
+
+	if len(metricFacts) == 0 {
+		return evaluation, nil
+	}
+
+	// The checkout is named after the component so facts can keep using ${Metadata.Name}
+	// as repo, whatever the repository is called and wherever the component lives in it
+	// Clients without links, like searches or older recordings, get the default repository
+	repository := services.GitInfo{Host: services.DefaultGitHost, Owner: services.CurrentConfig().GitHubOrg, Repo: componentName}
+	if component.Repository != nil {
+		repository = *component.Repository
+	}
+	if r.opts.Ref != "" {
+		repository.Branch = r.opts.Ref
+	}
+	repository.Before = r.opts.At
+	for i, p := range sparsePaths {
+		sparsePaths[i] = path.Join(repository.Path, p)
+	}
+	if fullCheckout {
+		sparsePaths = nil
+		if repository.Path != "" {
+			sparsePaths = []string{repository.Path}
+		}
+	}
+
+	// Checkouts get their own directory so no component name can clash with the catalog
+	checkoutPath := r.workspace.Path(componentsDir, componentName)
+	evaluation.Commit, err = r.fetcher.Clone(&repository, checkoutPath, sparsePaths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone repository '%s/%s': %w", repository.Owner, repository.Repo, err)
+	}
+	if verbose {
+		fmt.Printf("Successfully cloned repository: %s/%s/%s at commit %s\n", repository.Host, repository.Owner, repository.Repo, evaluation.Commit)
+	}
+	// Checkouts are only needed while the component is evaluated
+	defer func() {
+		if !r.opts.KeepWorkspace {
+			_ = os.RemoveAll(checkoutPath)
+		}
+	}()
+
+	evalOpts := facts.Options{
+		BasePath:   r.workspace.Path(componentsDir),
+		RepoRoots:  map[string]string{componentName: filepath.Join(checkoutPath, repository.Path)},
+		Prometheus: r.deps.Prometheus,
+		HTTPClient: r.deps.HTTPClient,
+		At:         r.opts.At,
+	}
 
-		evaluatedResult, err := facts.EvaluateMetric(metricFacts, component.Name)
+	for _, metric := range component.Metrics {
+		factList, ok := metricFacts[metric.Name]
+		if !ok {
+			continue
+		}
+		result := MetricResult{Metric: metric}
+		result.Definition, _ = r.metrics.Lookup(metric.Name, component.Type)
+
+		if verbose {
+			fmt.Printf("Processing metric: %s\n", metric.Name)
+		}
+
+		var value float64
+		evaluatedResult, err := facts.EvaluateMetric(factList, component.Name, evalOpts)
+		if err == nil {
+			value, result.Value, err = facts.ConvertValue(evaluatedResult, result.Definition.Spec.Format)
+		}
 		if err != nil {
+			result.Err = err
 			if verbose {
-				fmt.Printf("Warning: failed to evaluate metric '%s': %v\n", metric.Name, err)
+				fmt.Printf("Warning: failed to evaluate metric '%s' (%s): %v\n", metric.Name, result.Definition.Pos, err)
+			}
+		} else {
+			if thresholds := result.Definition.Spec.Thresholds; thresholds != nil {
+				result.Level, result.Rank = thresholds.Level(value)
 			}
+			if verbose {
+				fmt.Printf("Evaluated metric '%s' with value: %s%s\n", metric.Name, result.Value, levelSuffix(result.Level))
+			}
+		}
+		evaluation.Results = append(evaluation.Results, result)
+	}
+
+	return evaluation, nil
+}
+
+// Process evaluates the metrics of a component and submits their values.
+func (r *Run) Process(componentName string) error {
+	verbose := r.opts.Verbose
+
+	evaluation, err := r.Evaluate(componentName)
+	if err != nil {
+		return err
+	}
+	if len(evaluation.Results) == 0 {
+		fmt.Printf("No metrics to compute for component '%s'\n", componentName)
+		return nil
+	}
+	component, commit := evaluation.Component, evaluation.Commit
+	r.grade(evaluation)
+
+	queued, unchanged := 0, 0
+	for _, result := range evaluation.Results {
+		if result.Err != nil {
 			continue
 		}
 
-		value := fmt.Sprintf("%v", evaluatedResult)
+		submissions := []MetricResult{result}
+		if levelMetric := result.Definition.Spec.LevelMetric(); levelMetric != "" {
+			if metric, ok := componentMetric(component, levelMetric); ok {
+				level := result
+				level.Metric, level.Value = metric, strconv.Itoa(result.Rank)
+				submissions = append(submissions, level)
+			} else if verbose {
+				fmt.Printf("Warning: component '%s' has no source for level metric '%s' of '%s'\n", componentName, levelMetric, result.Metric.Name)
+			}
+		}
 
-		if verbose {
-			fmt.Printf("Evaluated metric '%s' with value: %s\n", metric.Name, value)
+		for _, submission := range submissions {
+			if r.submit(component, commit, submission) {
+				queued++
+			} else {
+				unchanged++
+			}
 		}
+	}
 
-		if err := compass.PutMetric(component.ID, metric.DefinitionID, value); err != nil {
-			fmt.Printf("Error submitting metric '%s': %v\n", metric.Name, err)
+	if unchanged > 0 {
+		fmt.Printf("Skipped %d unchanged metrics for component '%s'\n", unchanged, componentName)
+	}
+	// Compass only confirms values when the queue is flushed, which reports the outcome
+	if r.opts.DryRun {
+		fmt.Printf("Evaluated %d metrics for component '%s' at commit %s\n", queued, componentName, commit)
+	} else {
+		fmt.Printf("Queued %d metric values for component '%s' at commit %s\n", queued, componentName, commit)
+	}
+	return nil
+}
+
+// submit queues the value of result for Compass, or only records it when it
+// is unchanged. It reports whether the value was queued; dry runs count as
+// queued.
+func (r *Run) submit(component *services.Component, commit string, result MetricResult) bool {
+	metric, value := result.Metric, result.Value
+	if r.opts.DryRun {
+		fmt.Printf("Dry run: not submitting metric '%s' with value %s%s\n", metric.Name, value, levelSuffix(result.Level))
+		return true
+	}
+
+	record := history.Record{
+		Component:      component.Name,
+		Metric:         metric.Name,
+		Value:          value,
+		Level:          result.Level,
+		Timestamp:      r.timestamp(),
+		Commit:         commit,
+		DefinitionHash: history.DefinitionHash(*result.Definition),
+	}
+
+	if last, ok := r.lastSubmitted(component.Name, metric.Name, record.Timestamp); ok && r.opts.OnlyChanged && last.Value == value {
+		if r.opts.Verbose {
+			fmt.Printf("Skipping metric '%s': value %s unchanged since %s\n", metric.Name, value, last.Timestamp.Format(time.RFC3339))
+		}
+		r.recordHistory(record)
+		return false
+	}
+
+	// Values are sent in batches; the history is written once Compass stored them
+	r.queue.Add(Submission{
+		Component: component.Name,
+		Metric:    metric.Name,
+		MetricSubmission: services.MetricSubmission{
+			ComponentID:        component.ID,
+			MetricDefinitionID: metric.DefinitionID,
+			MetricSourceID:     metric.SourceID,
+			Value:              value,
+			Timestamp:          record.Timestamp,
+		},
+		record: &record,
+	})
+	return true
+}
+
+// componentMetric returns the metric source of the component named name.
+func componentMetric(component *services.Component, name string) (services.Metric, bool) {
+	for _, metric := range component.Metrics {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+	return services.Metric{}, false
+}
+
+func levelSuffix(level string) string {
+	if level == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (level %s)", level)
+}
+
+// grade scores the evaluated component on the scorecards for its type.
+func (r *Run) grade(evaluation *Evaluation) {
+	if len(r.scorecards) == 0 {
+		return
+	}
+	values := make(map[string]float64)
+	for _, result := range evaluation.Results {
+		if result.Err != nil {
 			continue
 		}
+		if value, err := strconv.ParseFloat(result.Value, 64); err == nil {
+			values[result.Metric.Name] = value
+		}
+		if levelMetric := result.Definition.Spec.LevelMetric(); levelMetric != "" {
+			values[levelMetric] = float64(result.Rank)
+		}
+	}
 
-		processed++
+	component := evaluation.Component
+	for _, card := range r.scorecards {
+		if !card.AppliesTo(component.Type) {
+			continue
+		}
+		result := card.Grade(component.Name, values, r.filteredMetrics(card, component.Type))
+		if r.opts.Verbose {
+			fmt.Printf("Component '%s' %s\n", component.Name, result.Summary())
+		}
+		r.grades = append(r.grades, result)
 	}
+}
 
-	fmt.Printf("Successfully processed %d metrics for component '%s'\n", processed, componentName)
-	return nil
+// filteredMetrics returns the criteria metrics of card that the metric filter
+// of the run excludes, so grading can tell them from metrics without a value.
+// Level metrics follow the metric they grade.
+func (r *Run) filteredMetrics(card scorecard.Scorecard, componentType string) map[string]bool {
+	filtered := make(map[string]bool)
+	for _, criterion := range card.Spec.Criteria {
+		metric := criterion.Metric
+		if source, ok := r.metrics.LevelSource(metric, componentType); ok {
+			metric = source.Metadata.Name
+		}
+		if !r.opts.Metrics.matches(r.metrics, metric, componentType) {
+			filtered[criterion.Metric] = true
+		}
+	}
+	return filtered
 }
 
-func ProcessAll(componentList []string, verbose bool, allComponents bool) error {
-	compass := services.NewCompassService()
-	if allComponents {
-		if verbose {
-			list, err := compass.GetAllComponentList()
-			if err != nil {
-				return err
+// Flush submits the queued metric values. Values that could not be stored are
+// saved to the failed file and reported in the error.
+func (r *Run) Flush() error {
+	r.queue.Flush()
+	if stored, requests := r.queue.Stored(); requests > 0 {
+		fmt.Printf("Submitted %d metric values in %d requests\n", stored, requests)
+	}
+	return ReportFailed(r.queue.Failed(), r.opts.FailedFile, false)
+}
+
+// timestamp is the time values of this run are submitted for.
+func (r *Run) timestamp() time.Time {
+	if r.opts.At.IsZero() {
+		return time.Now().UTC()
+	}
+	return r.opts.At.UTC()
+}
+
+func (r *Run) lastSubmitted(component, metric string, at time.Time) (history.Record, bool) {
+	if r.history == nil {
+		return history.Record{}, false
+	}
+	return r.history.LastSubmitted(component, metric, at)
+}
+
+// recordHistory appends to the history file, if any. Failing to record does
+// not fail the run; the value has been handled already.
+func (r *Run) recordHistory(record history.Record) {
+	if r.history == nil {
+		return
+	}
+	if err := r.history.Append(record); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// ProcessAll resolves the selected components and processes them in one run.
+func ProcessAll(deps Dependencies, selector Selector, opts Options) error {
+	return processAll(deps, selector, opts, []time.Time{opts.At})
+}
+
+// Backfill processes the selected components as of each of the given times,
+// sharing one run and one submission queue.
+func Backfill(deps Dependencies, selector Selector, opts Options, times []time.Time) error {
+	if len(times) == 0 {
+		return fmt.Errorf("no timestamps to backfill")
+	}
+	return processAll(deps, selector, opts, times)
+}
+
+func processAll(deps Dependencies, selector Selector, opts Options, times []time.Time) error {
+	if deps.Compass == nil {
+		deps.Compass = services.NewCompassService()
+	}
+
+	componentList, err := selector.Resolve(deps.Compass)
+	if err != nil {
+		return err
+	}
+	if opts.Verbose {
+		fmt.Printf("Processing %d components: %v\n", len(componentList), componentList)
+	}
+
+	run, err := NewRun(deps, opts)
+	if err != nil {
+		return err
+	}
+	stop := run.workspace.CleanupOnInterrupt()
+	defer stop()
+	defer func() {
+		if err := run.Close(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}()
+
+	defer func() {
+		if grades := run.Grades(); len(grades) > 0 {
+			fmt.Println()
+			if err := scorecard.PrintResults(os.Stdout, grades, opts.Verbose); err != nil {
+				fmt.Printf("Warning: %v\n", err)
 			}
-			fmt.Printf("Processing all components: %v\n", list)
-			return nil
 		}
-	}
+	}()
 
-	for _, componentName := range componentList {
-		if err := Process(componentName, verbose, compass); err != nil {
-			return fmt.Errorf("failed to process component '%s': %w", componentName, err)
+	for _, at := range times {
+		run.opts.At = at
+		if !at.IsZero() {
+			fmt.Printf("Evaluating as of %s\n", at.UTC().Format(time.RFC3339))
+		}
+		for _, componentName := range componentList {
+			if err := run.Process(componentName); err != nil {
+				// Values of the components processed so far are still submitted
+				return errors.Join(fmt.Errorf("failed to process component '%s': %w", componentName, err), run.Flush())
+			}
 		}
 	}
 
-	return nil
+	return run.Flush()
+}
+
+// MaxTimestamps bounds the timestamps of one backfill; each one clones and
+// evaluates every selected component again.
+const MaxTimestamps = 10000
 
+// Timestamps returns the times from from to to, both included, interval apart.
+// Ranges of more than MaxTimestamps times are rejected.
+func Timestamps(from, to time.Time, interval time.Duration) ([]time.Time, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("--to %s is before --from %s", to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+	if n := to.Sub(from)/interval + 1; n > MaxTimestamps {
+		return nil, fmt.Errorf("%d timestamps from %s to %s every %s is more than %d; use a larger --interval or a shorter range",
+			n, from.Format(time.RFC3339), to.Format(time.RFC3339), interval, MaxTimestamps)
+	}
+	var times []time.Time
+	for at := from; !at.After(to); at = at.Add(interval) {
+		times = append(times, at)
+	}
+	return times, nil
 }
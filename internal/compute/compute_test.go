@@ -0,0 +1,180 @@
+package compute
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/motain/compass-compute/internal/fakes"
+	"github.com/motain/compass-compute/internal/services"
+)
+
+const computeMetrics = `apiVersion: v2
+kind: Metric
+metadata:
+  name: test-coverage
+  componentType: ["service"]
+spec:
+  format:
+    unit: "%"
+  facts:
+    - id: coverage
+      type: extract
+      source: github
+      repo: ${Metadata.Name}
+      filePath: coverage.json
+      rule: jsonpath
+      jsonPath: ".total.pct"
+---
+apiVersion: v2
+kind: Metric
+metadata:
+  name: uptime
+  componentType: ["service"]
+spec:
+  format:
+    unit: "%"
+  facts:
+    - id: uptime
+      type: extract
+      source: prometheus
+      prometheusQuery: 'up{service="${Metadata.Name}"}'
+      rule: instant
+`
+
+// computeFixture sets up a local metric directory and a fake Compass with one
+// service reporting both metrics of computeMetrics.
+func computeFixture(t *testing.T) (Dependencies, *fakes.Compass) {
+	t.Helper()
+	metricDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(metricDir, "metrics.yaml"), []byte(computeMetrics), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := services.CurrentConfig()
+	cfg := services.DefaultConfig()
+	cfg.MetricDir = metricDir
+	services.SetConfig(&cfg)
+	t.Cleanup(func() {
+		if previous != nil {
+			services.SetConfig(previous)
+		}
+	})
+
+	compass := fakes.NewCompass()
+	compass.AddComponent(fakes.Component{Component: services.Component{
+		ID:         "component-1",
+		Name:       "Payments service",
+		Type:       "SERVICE",
+		Repository: &services.GitInfo{Host: services.DefaultGitHost, Owner: "motain", Repo: "svc"},
+	}, Slug: "svc"})
+	for _, metric := range []string{"test-coverage", "uptime"} {
+		compass.AddMetricDefinition(services.CompassMetricDefinition{Name: metric, Unit: "%"})
+		if _, err := compass.AttachMetric("svc", metric); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fetcher := fakes.NewRepoFetcher()
+	fetcher.Add("motain", "svc", map[string]string{"coverage.json": `{"total": {"pct": 85.5}}`})
+	prometheus := fakes.NewPrometheus()
+	prometheus.Instant[`up{service="svc"}`] = 99.9
+
+	return Dependencies{Compass: compass, Fetcher: fetcher, Prometheus: prometheus}, compass
+}
+
+func TestProcessAll(t *testing.T) {
+	deps, compass := computeFixture(t)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	err := ProcessAll(deps, Selector{Names: []string{"svc"}}, Options{
+		WorkspaceRoot: t.TempDir(),
+		FailedFile:    filepath.Join(t.TempDir(), "failed.jsonl"),
+		At:            at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definitions, _ := compass.GetMetricDefinitions()
+	want := map[string]string{definitions[0].ID: "85.5", definitions[1].ID: "99.9"}
+	submissions := compass.Submissions()
+	if len(submissions) != len(want) {
+		t.Fatalf("Compass got %d values, want %d: %+v", len(submissions), len(want), submissions)
+	}
+	for _, submission := range submissions {
+		if submission.ComponentID != "component-1" || submission.Value != want[submission.MetricDefinitionID] || !submission.Time.Equal(at) {
+			t.Errorf("unexpected submission %+v", submission)
+		}
+	}
+}
+
+// linklessCompass returns components without a repository link.
+type linklessCompass struct {
+	*fakes.Compass
+}
+
+func (c linklessCompass) GetComponent(name string) (*services.Component, error) {
+	component, err := c.Compass.GetComponent(name)
+	if component != nil {
+		component.Repository = nil
+	}
+	return component, err
+}
+
+func TestProcessAllDefaultRepository(t *testing.T) {
+	deps, compass := computeFixture(t)
+	deps.Compass = linklessCompass{compass}
+	deps.Fetcher.(*fakes.RepoFetcher).Add(services.CurrentConfig().GitHubOrg, "svc", map[string]string{"coverage.json": `{"total": {"pct": 70}}`})
+
+	err := ProcessAll(deps, Selector{Names: []string{"svc"}}, Options{WorkspaceRoot: t.TempDir(), Metrics: MetricFilter{Names: []string{"test-coverage"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submissions := compass.Submissions(); len(submissions) != 1 || submissions[0].Value != "70" {
+		t.Errorf("Compass got %+v, want the coverage of the default repository", submissions)
+	}
+}
+
+func TestProcessAllDryRun(t *testing.T) {
+	deps, compass := computeFixture(t)
+
+	err := ProcessAll(deps, Selector{All: true}, Options{WorkspaceRoot: t.TempDir(), DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submissions := compass.Submissions(); len(submissions) != 0 {
+		t.Errorf("dry run submitted %+v", submissions)
+	}
+}
+
+func TestProcessAllUnknownComponent(t *testing.T) {
+	deps, compass := computeFixture(t)
+
+	err := ProcessAll(deps, Selector{Names: []string{"missing"}}, Options{WorkspaceRoot: t.TempDir()})
+	if err == nil {
+		t.Fatal("ProcessAll() of an unknown component succeeded")
+	}
+	if submissions := compass.Submissions(); len(submissions) != 0 {
+		t.Errorf("Compass got values for an unknown component: %+v", submissions)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	times, err := Timestamps(from, from.Add(50*time.Hour), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 3 || !times[2].Equal(from.Add(48*time.Hour)) {
+		t.Errorf("Timestamps() = %v, want 3 days from %s", times, from)
+	}
+
+	if _, err := Timestamps(from, from.Add(MaxTimestamps*time.Minute), time.Minute); err == nil {
+		t.Errorf("Timestamps() of %d points succeeded", MaxTimestamps+1)
+	}
+	if _, err := Timestamps(from, from.Add(-time.Hour), time.Minute); err == nil {
+		t.Error("Timestamps() with --to before --from succeeded")
+	}
+}
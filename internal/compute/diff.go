@@ -0,0 +1,111 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Diff statuses.
+const (
+	DiffNew        = "new"        // Compass has no value yet
+	DiffUnchanged  = "unchanged"  // equal values
+	DiffImproved   = "improved"   // moved in the metric's direction
+	DiffRegression = "REGRESSION" // moved against the metric's direction
+	DiffChanged    = "changed"    // differs, but not numerically comparable
+	DiffError      = "error"      // local evaluation failed
+)
+
+// MetricDiff compares the value of a metric in Compass with the local one.
+type MetricDiff struct {
+	Metric string
+	Old    *services.MetricValue
+	New    string
+	// OldLevel and NewLevel are the levels of the values, for metrics with thresholds
+	OldLevel string
+	NewLevel string
+	Delta    float64 // New - Old, when both are numbers
+	Status   string
+	Err      error
+}
+
+// Diff compares every evaluated metric with its latest value in Compass.
+func Diff(evaluation *Evaluation) []MetricDiff {
+	diffs := make([]MetricDiff, 0, len(evaluation.Results))
+	for _, result := range evaluation.Results {
+		diff := MetricDiff{Metric: result.Metric.Name, Old: result.Metric.Latest, New: result.Value, NewLevel: result.Level, Err: result.Err}
+		if result.Definition != nil && result.Definition.Spec.Thresholds != nil && diff.Old != nil {
+			diff.OldLevel, _ = result.Definition.Spec.Thresholds.Level(diff.Old.Value)
+		}
+
+		newValue, err := strconv.ParseFloat(result.Value, 64)
+		switch {
+		case result.Err != nil:
+			diff.Status = DiffError
+		case diff.Old == nil:
+			diff.Status = DiffNew
+		case err != nil:
+			diff.Status = DiffChanged
+		default:
+			diff.Delta = newValue - diff.Old.Value
+			lowerIsBetter := result.Definition != nil && result.Definition.Spec.LowerIsBetter()
+			switch {
+			case diff.Delta == 0:
+				diff.Status = DiffUnchanged
+			case (diff.Delta < 0) == lowerIsBetter:
+				diff.Status = DiffImproved
+			default:
+				diff.Status = DiffRegression
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// Regressions counts the diffs whose value got worse.
+func Regressions(diffs []MetricDiff) int {
+	count := 0
+	for _, diff := range diffs {
+		if diff.Status == DiffRegression {
+			count++
+		}
+	}
+	return count
+}
+
+// PrintDiff writes the diffs side by side as a table.
+func PrintDiff(w io.Writer, diffs []MetricDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tCOMPASS\tLOCAL\tCHANGE\tSTATUS")
+	for _, diff := range diffs {
+		old, change := "-", ""
+		if diff.Old != nil {
+			old = fmt.Sprintf("%s (%s)", withLevel(strconv.FormatFloat(diff.Old.Value, 'f', -1, 64), diff.OldLevel), diff.Old.Timestamp.Format(time.RFC3339))
+			if diff.Status == DiffImproved || diff.Status == DiffRegression {
+				change = fmt.Sprintf("%+g", diff.Delta)
+			}
+			if diff.OldLevel != diff.NewLevel && diff.NewLevel != "" {
+				change = strings.TrimSpace(change + " " + diff.OldLevel + " -> " + diff.NewLevel)
+			}
+		}
+		local, status := withLevel(diff.New, diff.NewLevel), diff.Status
+		if diff.Err != nil {
+			local, status = "-", fmt.Sprintf("%s: %v", DiffError, diff.Err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", diff.Metric, old, local, change, status)
+	}
+	return tw.Flush()
+}
+
+func withLevel(value, level string) string {
+	if level == "" {
+		return value
+	}
+	return value + " " + level
+}
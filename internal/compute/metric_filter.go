@@ -0,0 +1,50 @@
+package compute
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// MetricFilter restricts which of a component's metrics are computed. A
+// metric must be named in Names, when set, and carry every label in Labels.
+type MetricFilter struct {
+	Names  []string
+	Labels map[string]string
+}
+
+func (f MetricFilter) matches(registry *services.MetricRegistry, metricName, componentType string) bool {
+	if len(f.Names) > 0 && !slices.Contains(f.Names, metricName) {
+		return false
+	}
+	if len(f.Labels) == 0 {
+		return true
+	}
+
+	definition, ok := registry.Lookup(metricName, componentType)
+	if !ok {
+		return false
+	}
+	for key, value := range f.Labels {
+		if definition.Metadata.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validate rejects metric names that no definition in the registry carries,
+// which are most likely typos.
+func (f MetricFilter) validate(registry *services.MetricRegistry) error {
+	known := make(map[string]bool)
+	for _, definition := range registry.Definitions() {
+		known[definition.Metadata.Name] = true
+	}
+	for _, name := range f.Names {
+		if !known[name] {
+			return fmt.Errorf("unknown metric '%s': no definition in the metric directory", name)
+		}
+	}
+	return nil
+}
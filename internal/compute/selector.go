@@ -0,0 +1,120 @@
+package compute
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Selector picks the components a run processes. Names are used as given
+// unless a search is requested (All or any Filter field), in which case the
+// search results are used, narrowed to Names when both are present.
+// Excluded names are dropped last.
+type Selector struct {
+	Names   []string
+	All     bool
+	Filter  services.ComponentFilter
+	Owner   string // owner team by name or ID, resolved into Filter.OwnerID
+	Exclude []string
+}
+
+func (s Selector) searches() bool {
+	return s.All || len(s.Filter.Types) > 0 || len(s.Filter.Labels) > 0 || s.Filter.OwnerID != "" || s.Owner != ""
+}
+
+// Resolve returns the selected component names, without duplicates, in a
+// stable order.
+func (s Selector) Resolve(compass services.CompassClient) ([]string, error) {
+	names := s.Names
+
+	if s.searches() {
+		if s.Owner != "" {
+			ownerID, err := resolveTeam(compass, s.Owner)
+			if err != nil {
+				return nil, err
+			}
+			s.Filter.OwnerID = ownerID
+		}
+		components, err := compass.ListComponents(s.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search components: %w", err)
+		}
+
+		found := make([]string, len(components))
+		for i, component := range components {
+			found[i] = component.Name
+		}
+
+		if len(s.Names) == 0 {
+			names = found
+		} else {
+			names = intersect(s.Names, found)
+		}
+	}
+
+	excluded := make(map[string]bool)
+	for _, name := range s.Exclude {
+		excluded[name] = true
+	}
+
+	seen := make(map[string]bool)
+	var selected []string
+	for _, name := range names {
+		if name == "" || excluded[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		selected = append(selected, name)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no components match the selection")
+	}
+	return selected, nil
+}
+
+// teamID matches a team ID, as an ARI or the bare UUID.
+var teamID = regexp.MustCompile(`(?i)^(ari:cloud:identity::team/)?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// resolveTeam returns owner when it is a team ID, or else the ID of the one
+// team named owner.
+func resolveTeam(compass services.CompassClient, owner string) (string, error) {
+	if strings.HasPrefix(owner, "ari:") || teamID.MatchString(owner) {
+		return owner, nil
+	}
+
+	teams, err := compass.SearchTeams(owner)
+	if err != nil {
+		return "", fmt.Errorf("failed to search team '%s': %w", owner, err)
+	}
+	var ids []string
+	for _, team := range teams {
+		if strings.EqualFold(team.Name, owner) {
+			ids = append(ids, team.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no team named '%s'", owner)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("%d teams are named '%s', pass the team ID instead: %s", len(ids), owner, strings.Join(ids, ", "))
+}
+
+// intersect keeps the names of a that are also in b, in the order of a.
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, name := range b {
+		in[name] = true
+	}
+	var result []string
+	for _, name := range a {
+		if in[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
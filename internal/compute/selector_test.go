@@ -0,0 +1,41 @@
+package compute
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motain/compass-compute/internal/fakes"
+	"github.com/motain/compass-compute/internal/services"
+)
+
+func TestSelectorResolveOwner(t *testing.T) {
+	compass := fakes.NewCompass()
+	platform := compass.AddTeam("Platform")
+	compass.AddTeam("Platform Tools")
+	compass.AddTeam("Payments")
+	compass.AddTeam("payments")
+	compass.AddComponent(fakes.Component{Component: services.Component{Name: "api", Type: "SERVICE"}, OwnerID: platform})
+	compass.AddComponent(fakes.Component{Component: services.Component{Name: "web", Type: "SERVICE"}})
+
+	tests := []struct {
+		owner string
+		want  string // selected components, or the error
+	}{
+		{owner: "platform", want: "api"},
+		{owner: platform, want: "api"},
+		{owner: "ari:cloud:identity::team/unknown", want: "no components match the selection"},
+		{owner: "0b4c5e6f-1a2b-4c3d-8e9f-0a1b2c3d4e5f", want: "no components match the selection"},
+		{owner: "Search", want: "no team named 'Search'"},
+		{owner: "Payments", want: "2 teams are named 'Payments'"},
+	}
+	for _, tt := range tests {
+		names, err := Selector{Owner: tt.owner}.Resolve(compass)
+		got := strings.Join(names, ",")
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Resolve() with owner %q = %q, want %q", tt.owner, got, tt.want)
+		}
+	}
+}
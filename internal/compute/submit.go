@@ -0,0 +1,209 @@
+package compute
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/motain/compass-compute/internal/history"
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Submission defaults.
+const (
+	DefaultBatchSize     = 50
+	DefaultSubmitRetries = 3
+	DefaultFailedFile    = "compass-compute-failed.jsonl"
+)
+
+// Submission is a queued metric value with the names it is reported by.
+type Submission struct {
+	Component string `json:"component"`
+	Metric    string `json:"metric"`
+	services.MetricSubmission
+	Error string `json:"error,omitempty"` // last failure, when it could not be stored
+
+	record *history.Record // appended to the history once stored
+}
+
+// SubmitQueue sends metric values to Compass in batches. Values Compass
+// provably did not store are retried with backoff; values that may have been
+// stored, e.g. after a timeout, are not, so nothing is submitted twice.
+// Values still failing are kept in Failed.
+type SubmitQueue struct {
+	compass   services.CompassClient
+	batchSize int
+	retries   int
+	verbose   bool
+	onStored  func(Submission)
+
+	pending []Submission
+	failed  []Submission
+	stored  int
+	batches int
+}
+
+func NewSubmitQueue(compass services.CompassClient, batchSize, retries int, verbose bool) *SubmitQueue {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &SubmitQueue{compass: compass, batchSize: batchSize, retries: retries, verbose: verbose}
+}
+
+// Add queues a value, sending a batch once enough values are queued.
+func (q *SubmitQueue) Add(submission Submission) {
+	q.pending = append(q.pending, submission)
+	if len(q.pending) >= q.batchSize {
+		q.Flush()
+	}
+}
+
+// Flush sends every queued value.
+func (q *SubmitQueue) Flush() {
+	for len(q.pending) > 0 {
+		n := min(q.batchSize, len(q.pending))
+		batch := q.pending[:n]
+		q.pending = q.pending[n:]
+		q.send(batch)
+	}
+}
+
+// send submits a batch, retrying the values that failed.
+func (q *SubmitQueue) send(batch []Submission) {
+	retryConfig := services.CurrentHTTPConfig()
+	for attempt := 0; len(batch) > 0; attempt++ {
+		if attempt > 0 {
+			delay := services.Backoff(attempt-1, retryConfig)
+			if q.verbose {
+				fmt.Printf("Retrying %d metric values in %s (attempt %d/%d)\n", len(batch), delay, attempt, q.retries)
+			}
+			time.Sleep(delay)
+		}
+
+		values := make([]services.MetricSubmission, len(batch))
+		for i, submission := range batch {
+			values[i] = submission.MetricSubmission
+		}
+		errs := q.compass.PutMetrics(values)
+		q.batches++
+
+		var retry []Submission
+		for i, submission := range batch {
+			if errs[i] == nil {
+				q.stored++
+				if q.onStored != nil {
+					q.onStored(submission)
+				}
+				continue
+			}
+			submission.Error = errs[i].Error()
+			if !services.IsRetryable(errs[i]) {
+				q.failed = append(q.failed, submission)
+				continue
+			}
+			retry = append(retry, submission)
+		}
+
+		if attempt == q.retries {
+			q.failed = append(q.failed, retry...)
+			return
+		}
+		batch = retry
+	}
+}
+
+// Stored returns how many values Compass accepted, and in how many requests.
+func (q *SubmitQueue) Stored() (values, requests int) {
+	return q.stored, q.batches
+}
+
+// Failed returns the values that could not be stored after all retries.
+func (q *SubmitQueue) Failed() []Submission {
+	return q.failed
+}
+
+// SaveSubmissions writes submissions to path as JSON lines, replacing it.
+func SaveSubmissions(path string, submissions []Submission) error {
+	return writeSubmissions(path, os.O_TRUNC, submissions)
+}
+
+// AppendSubmissions adds submissions to path as JSON lines, keeping the values
+// earlier runs saved there.
+func AppendSubmissions(path string, submissions []Submission) error {
+	return writeSubmissions(path, os.O_APPEND, submissions)
+}
+
+func writeSubmissions(path string, mode int, submissions []Submission) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|mode, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, submission := range submissions {
+		if err := encoder.Encode(submission); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadSubmissions reads submissions saved by SaveSubmissions.
+func LoadSubmissions(path string) ([]Submission, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var submissions []Submission
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var submission Submission
+		if err := json.Unmarshal(scanner.Bytes(), &submission); err != nil {
+			return nil, fmt.Errorf("failed to parse %s:%d: %w", path, line, err)
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return submissions, nil
+}
+
+// ReportFailed prints the values that could not be stored and adds them to
+// path, so they can be resent without evaluating again. With replace set the
+// file is rewritten instead, as when resending the values it holds.
+func ReportFailed(failed []Submission, path string, replace bool) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	for _, submission := range failed {
+		fmt.Printf("Error submitting metric '%s' for component '%s': %s\n", submission.Metric, submission.Component, submission.Error)
+	}
+	if path == "" {
+		path = DefaultFailedFile
+	}
+	save := AppendSubmissions
+	if replace {
+		save = SaveSubmissions
+	}
+	if err := save(path, failed); err != nil {
+		return errors.Join(fmt.Errorf("%d metric values could not be submitted", len(failed)), err)
+	}
+	return fmt.Errorf("%d metric values could not be submitted; saved to %s, resend them with 'compass-compute submit %s'",
+		len(failed), path, path)
+}
@@ -0,0 +1,176 @@
+package compute
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/motain/compass-compute/internal/fakes"
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// countingCompass counts the values sent for each metric source.
+type countingCompass struct {
+	*fakes.Compass
+	sent map[string]int
+}
+
+func (c *countingCompass) PutMetrics(submissions []services.MetricSubmission) []error {
+	for _, submission := range submissions {
+		c.sent[submission.MetricSourceID]++
+	}
+	return c.Compass.PutMetrics(submissions)
+}
+
+// newSubmitCompass returns a fake Compass with a component reporting the
+// given metrics, and the metric source of each.
+func newSubmitCompass(t *testing.T, metrics ...string) (*countingCompass, map[string]string) {
+	t.Helper()
+	compass := fakes.NewCompass()
+	compass.AddComponent(fakes.Component{Component: services.Component{ID: "component-1", Name: "svc", Type: "SERVICE"}})
+	sources := map[string]string{}
+	for _, metric := range metrics {
+		compass.AddMetricDefinition(services.CompassMetricDefinition{Name: metric})
+		sourceID, err := compass.AttachMetric("svc", metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[metric] = sourceID
+	}
+	return &countingCompass{Compass: compass, sent: map[string]int{}}, sources
+}
+
+// fastRetries makes retries wait at most a millisecond for the test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	previous := services.CurrentHTTPConfig()
+	cfg := previous
+	cfg.RetryBaseDelay = time.Microsecond
+	cfg.RetryMaxDelay = time.Millisecond
+	services.SetHTTPConfig(cfg)
+	t.Cleanup(func() { services.SetHTTPConfig(previous) })
+}
+
+func submission(metric, sourceID, value string) Submission {
+	return Submission{Component: "svc", Metric: metric, MetricSubmission: services.MetricSubmission{
+		ComponentID: "component-1", MetricSourceID: sourceID, Value: value, Timestamp: time.Now(),
+	}}
+}
+
+func TestSubmitQueueRetriesFailedValues(t *testing.T) {
+	fastRetries(t)
+	compass, sources := newSubmitCompass(t, "coverage", "incidents", "lead-time")
+	compass.FailSources[sources["incidents"]] = &services.RetryableError{Err: errors.New("API error 429: rate limited")}
+
+	queue := NewSubmitQueue(compass, 2, 2, false)
+	var stored []string
+	queue.onStored = func(s Submission) { stored = append(stored, s.Metric) }
+	queue.Add(submission("coverage", sources["coverage"], "85"))
+	queue.Add(submission("incidents", sources["incidents"], "2"))
+	queue.Add(submission("lead-time", sources["lead-time"], "3"))
+	queue.Flush()
+
+	if len(stored) != 2 || stored[0] != "coverage" || stored[1] != "lead-time" {
+		t.Errorf("onStored got %v, want [coverage lead-time]", stored)
+	}
+	if got := len(compass.Submissions()); got != 2 {
+		t.Errorf("Compass stored %d values, want 2", got)
+	}
+	// The first batch is sent once and retried twice, then the last value alone
+	if values, requests := queue.Stored(); values != 2 || requests != 4 {
+		t.Errorf("Stored() = %d values in %d requests, want 2 in 4", values, requests)
+	}
+	if got := compass.sent[sources["incidents"]]; got != 3 {
+		t.Errorf("failing value sent %d times, want 3", got)
+	}
+	failed := queue.Failed()
+	if len(failed) != 1 || failed[0].Metric != "incidents" || failed[0].Error != "API error 429: rate limited" {
+		t.Errorf("Failed() = %+v, want the incidents value with its error", failed)
+	}
+}
+
+func TestSubmitQueueDoesNotRetryUnknownOutcomes(t *testing.T) {
+	fastRetries(t)
+	compass, sources := newSubmitCompass(t, "coverage")
+	compass.FailSources[sources["coverage"]] = errors.New("request failed: context deadline exceeded")
+
+	queue := NewSubmitQueue(compass, DefaultBatchSize, 3, false)
+	queue.Add(submission("coverage", sources["coverage"], "85"))
+	queue.Flush()
+
+	if got := compass.sent[sources["coverage"]]; got != 1 {
+		t.Errorf("value that may have been stored sent %d times, want 1", got)
+	}
+	if len(queue.Failed()) != 1 {
+		t.Errorf("Failed() = %+v, want the coverage value", queue.Failed())
+	}
+}
+
+func TestSubmitQueueDoesNotResendAfterGatewayError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	previous := services.CurrentConfig()
+	cfg := services.DefaultConfig()
+	cfg.CompassBaseURL = server.URL
+	cfg.HTTP.RetryBaseDelay = time.Microsecond
+	cfg.HTTP.RetryMaxDelay = time.Millisecond
+	services.SetConfig(&cfg)
+	t.Cleanup(func() {
+		if previous != nil {
+			services.SetConfig(previous)
+		}
+	})
+
+	// Compass may have stored the values before the gateway gave up
+	queue := NewSubmitQueue(services.NewCompassService(), DefaultBatchSize, 3, false)
+	queue.Add(submission("coverage", "metric-source-1", "85"))
+	queue.Add(submission("incidents", "metric-source-2", "2"))
+	queue.Flush()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Compass got %d requests, want 1", got)
+	}
+	if failed := queue.Failed(); len(failed) != 2 {
+		t.Errorf("Failed() = %+v, want both values", failed)
+	}
+}
+
+func TestReportFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.jsonl")
+	first := submission("coverage", "metric-source-1", "85")
+	second := submission("incidents", "metric-source-2", "2")
+
+	if err := ReportFailed([]Submission{first}, path, false); err == nil {
+		t.Fatal("ReportFailed() returned no error for failed values")
+	}
+	if err := ReportFailed([]Submission{second}, path, false); err == nil {
+		t.Fatal("ReportFailed() returned no error for failed values")
+	}
+	saved, err := LoadSubmissions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("appending kept %d values, want 2", len(saved))
+	}
+
+	if err := ReportFailed([]Submission{second}, path, true); err == nil {
+		t.Fatal("ReportFailed() returned no error for failed values")
+	}
+	saved, err = LoadSubmissions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Metric != "incidents" || !saved[0].Timestamp.Equal(second.Timestamp) {
+		t.Errorf("replacing kept %+v, want only the incidents value", saved)
+	}
+}
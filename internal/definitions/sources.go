@@ -0,0 +1,155 @@
+package definitions
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// SourceChange is the planned creation or removal of one metric source, the
+// link between a component and a metric definition.
+type SourceChange struct {
+	Action       Action
+	Component    string
+	ComponentID  string
+	Metric       string
+	DefinitionID string
+	SourceID     string
+}
+
+// SourcePlan lists the metric source changes and the catalog metrics that
+// could not be planned because Compass has no definition for them yet.
+type SourcePlan struct {
+	Changes []SourceChange
+	Missing []string
+}
+
+// PlanSources works out which components need a metric source for which
+// catalog metric, based on the componentType lists of the metric YAML. Level
+// metrics go wherever the metric they grade goes. With
+// prune set, sources of catalog metrics on components whose type is no longer
+// listed are removed. Sources of metrics outside the catalog are never touched.
+func PlanSources(metrics []services.MetricDefinition, current []services.CompassMetricDefinition, components []services.Component, prune bool) SourcePlan {
+	componentTypes := make(map[string]map[string]bool)
+	for _, metric := range metrics {
+		for _, name := range []string{metric.Metadata.Name, metric.Spec.LevelMetric()} {
+			if name == "" {
+				continue
+			}
+			types, ok := componentTypes[name]
+			if !ok {
+				types = make(map[string]bool)
+				componentTypes[name] = types
+			}
+			for _, ct := range metric.Metadata.ComponentType {
+				types[strings.ToLower(ct)] = true
+			}
+		}
+	}
+
+	definitionIDs := make(map[string]string)
+	for _, definition := range current {
+		if !definition.BuiltIn {
+			definitionIDs[definition.Name] = definition.ID
+		}
+	}
+
+	var plan SourcePlan
+	var names []string
+	for name := range componentTypes {
+		if _, ok := definitionIDs[name]; !ok {
+			plan.Missing = append(plan.Missing, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(plan.Missing)
+
+	for _, component := range components {
+		sources := make(map[string]string)
+		for _, metric := range component.Metrics {
+			sources[metric.DefinitionID] = metric.SourceID
+		}
+
+		for _, name := range names {
+			definitionID := definitionIDs[name]
+			wanted := componentTypes[name][strings.ToLower(component.Type)]
+			sourceID, attached := sources[definitionID]
+
+			change := SourceChange{
+				Component:    component.Name,
+				ComponentID:  component.ID,
+				Metric:       name,
+				DefinitionID: definitionID,
+				SourceID:     sourceID,
+			}
+			switch {
+			case wanted && !attached:
+				change.Action = ActionCreate
+			case !wanted && attached && prune:
+				change.Action = ActionDelete
+			default:
+				continue
+			}
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Component < plan.Changes[j].Component
+	})
+	return plan
+}
+
+// PrintSourcePlan writes a diff-like summary of plan.
+func PrintSourcePlan(w io.Writer, plan SourcePlan) {
+	counts := make(map[Action]int)
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+	}
+	fmt.Fprintf(w, "Metric sources: %d to create, %d to remove\n", counts[ActionCreate], counts[ActionDelete])
+
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionCreate:
+			fmt.Fprintf(w, "  + %s: %s\n", change.Component, change.Metric)
+		case ActionDelete:
+			fmt.Fprintf(w, "  - %s: %s\n", change.Component, change.Metric)
+		}
+	}
+
+	for _, name := range plan.Missing {
+		fmt.Fprintf(w, "  ! %s has no metric definition in Compass, run 'definitions sync --apply' first\n", name)
+	}
+}
+
+// ApplySources creates and removes metric sources in Compass. It carries on
+// past failures and returns them together.
+func ApplySources(compass services.CompassClient, changes []SourceChange) error {
+	var failed []string
+	for _, change := range changes {
+		switch change.Action {
+		case ActionCreate:
+			if _, err := compass.CreateMetricSource(change.ComponentID, change.DefinitionID); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s: %v", change.Component, change.Metric, err))
+				continue
+			}
+			fmt.Printf("Attached metric '%s' to component '%s'\n", change.Metric, change.Component)
+		case ActionDelete:
+			if err := compass.DeleteMetricSource(change.SourceID); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s: %v", change.Component, change.Metric, err))
+				continue
+			}
+			fmt.Printf("Removed metric '%s' from component '%s'\n", change.Metric, change.Component)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d metric sources failed to sync:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package definitions keeps the metric definitions in Compass in line with
+// the metric YAML of the catalog, which is their source of truth.
+package definitions
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	ActionDelete    Action = "delete"
+	// ActionUnmanaged marks definitions that only exist in Compass; they are
+	// reported but never touched
+	ActionUnmanaged Action = "unmanaged"
+)
+
+// Change is the planned action for one metric definition.
+type Change struct {
+	Action  Action
+	Desired services.CompassMetricDefinition
+	Current *services.CompassMetricDefinition
+	Source  services.Position
+}
+
+// Desired derives the Compass definitions from the metric YAML, including the
+// level metrics of thresholds. A metric may be defined once per component
+// type, but all its definitions must agree on the description and unit.
+func Desired(metrics []services.MetricDefinition) ([]services.CompassMetricDefinition, map[string]services.Position, error) {
+	var desired []services.CompassMetricDefinition
+	sources := make(map[string]services.Position)
+	index := make(map[string]int)
+
+	for _, metric := range metrics {
+		definitions := []services.CompassMetricDefinition{{
+			Name:        metric.Metadata.Name,
+			Description: strings.TrimSpace(metric.Spec.Description),
+			Unit:        metric.Spec.Format.Unit,
+		}}
+		if level, ok := levelDefinition(metric); ok {
+			definitions = append(definitions, level)
+		}
+
+		for _, definition := range definitions {
+			if i, ok := index[definition.Name]; ok {
+				if desired[i] != definition {
+					return nil, nil, fmt.Errorf("metric '%s' has conflicting specs at %s and %s",
+						definition.Name, sources[definition.Name], metric.Pos)
+				}
+				continue
+			}
+			index[definition.Name] = len(desired)
+			desired = append(desired, definition)
+			sources[definition.Name] = metric.Pos
+		}
+	}
+
+	return desired, sources, nil
+}
+
+// levelDefinition is the Compass definition of the metric the level rank of
+// metric is submitted to, if its thresholds name one.
+func levelDefinition(metric services.MetricDefinition) (services.CompassMetricDefinition, bool) {
+	thresholds := metric.Spec.Thresholds
+	if thresholds == nil || thresholds.Metric == "" {
+		return services.CompassMetricDefinition{}, false
+	}
+
+	var ranks []string
+	for i, level := range thresholds.Levels {
+		ranks = append(ranks, fmt.Sprintf("%d %s", len(thresholds.Levels)-i, level.Name))
+	}
+	ranks = append(ranks, "0 "+thresholds.DefaultName())
+
+	return services.CompassMetricDefinition{
+		Name:        thresholds.Metric,
+		Description: fmt.Sprintf("Level of %s: %s", metric.Metadata.Name, strings.Join(ranks, ", ")),
+	}, true
+}
+
+// Plan compares the metric YAML with the definitions in Compass.
+func Plan(metrics []services.MetricDefinition, current []services.CompassMetricDefinition) ([]Change, error) {
+	desired, sources, err := Desired(metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*services.CompassMetricDefinition)
+	for i := range current {
+		byName[current[i].Name] = &current[i]
+	}
+
+	var changes []Change
+	for _, definition := range desired {
+		change := Change{Desired: definition, Source: sources[definition.Name]}
+		existing, ok := byName[definition.Name]
+		switch {
+		case !ok:
+			change.Action = ActionCreate
+		case existing.BuiltIn:
+			return nil, fmt.Errorf("metric '%s' (%s) clashes with a built-in Compass metric", definition.Name, change.Source)
+		case existing.Description != definition.Description || existing.Unit != definition.Unit:
+			change.Action = ActionUpdate
+			change.Desired.ID = existing.ID
+			change.Current = existing
+		default:
+			change.Action = ActionUnchanged
+			change.Desired.ID = existing.ID
+			change.Current = existing
+		}
+		delete(byName, definition.Name)
+		changes = append(changes, change)
+	}
+
+	for _, existing := range byName {
+		if !existing.BuiltIn {
+			changes = append(changes, Change{Action: ActionUnmanaged, Current: existing, Desired: *existing})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Desired.Name < changes[j].Desired.Name
+	})
+	return changes, nil
+}
+
+// Pending reports whether applying changes would modify Compass.
+func Pending(changes []Change) bool {
+	for _, change := range changes {
+		if change.Action == ActionCreate || change.Action == ActionUpdate {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintPlan writes a diff-like summary of changes. Unchanged definitions are
+// only listed when verbose is set.
+func PrintPlan(w io.Writer, changes []Change, verbose bool) {
+	counts := make(map[Action]int)
+	for _, change := range changes {
+		counts[change.Action]++
+	}
+	fmt.Fprintf(w, "Metric definitions: %d to create, %d to update, %d unchanged, %d only in Compass\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionUnchanged], counts[ActionUnmanaged])
+
+	for _, change := range changes {
+		desired := change.Desired
+		switch change.Action {
+		case ActionCreate:
+			fmt.Fprintf(w, "  + %s (%s)\n", desired.Name, change.Source)
+			fmt.Fprintf(w, "      description: %q\n", desired.Description)
+			fmt.Fprintf(w, "      unit: %q\n", desired.Unit)
+		case ActionUpdate:
+			fmt.Fprintf(w, "  ~ %s (%s)\n", desired.Name, change.Source)
+			if change.Current.Description != desired.Description {
+				fmt.Fprintf(w, "      description: %q -> %q\n", change.Current.Description, desired.Description)
+			}
+			if change.Current.Unit != desired.Unit {
+				fmt.Fprintf(w, "      unit: %q -> %q\n", change.Current.Unit, desired.Unit)
+			}
+		case ActionUnchanged:
+			if verbose {
+				fmt.Fprintf(w, "  = %s\n", desired.Name)
+			}
+		case ActionUnmanaged:
+			fmt.Fprintf(w, "  ? %s exists in Compass but not in the catalog, left untouched\n", desired.Name)
+		}
+	}
+}
+
+// Apply creates and updates definitions in Compass. It carries on past
+// failures and returns them together.
+func Apply(compass services.CompassClient, changes []Change) error {
+	var failed []string
+	for _, change := range changes {
+		switch change.Action {
+		case ActionCreate:
+			id, err := compass.CreateMetricDefinition(change.Desired)
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			fmt.Printf("Created metric definition '%s' (ID: %s)\n", change.Desired.Name, id)
+		case ActionUpdate:
+			if err := compass.UpdateMetricDefinition(change.Desired); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			fmt.Printf("Updated metric definition '%s'\n", change.Desired.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d metric definitions failed to sync:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
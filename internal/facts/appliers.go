@@ -30,28 +30,18 @@ func (fe *FactEvaluator) applyCustomRule(fact *services.Fact, data []byte) (inte
 	return string(data), nil
 }
 
-func (fe *FactEvaluator) applyJSONPath(jsonPath interface{}, data []byte) (interface{}, error) {
+func (fe *FactEvaluator) applyJSONPath(jsonPath string, data []byte) (interface{}, error) {
 	if len(data) == 0 {
 		return []interface{}{}, nil // Return empty array instead of nil
 	}
 
-	var jsonPathStr string
-	switch v := jsonPath.(type) {
-	case string:
-		jsonPathStr = v
-	case nil:
+	if jsonPath == "" {
 		return nil, fmt.Errorf("jsonPath is required but not provided")
-	default:
-		return nil, fmt.Errorf("jsonPath must be a string, got %T", jsonPath)
-	}
-
-	if jsonPathStr == "" {
-		return nil, fmt.Errorf("jsonPath cannot be empty")
 	}
 
-	query, err := gojq.Parse(jsonPathStr)
+	query, err := gojq.Parse(jsonPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse jsonPath '%s': %w", jsonPathStr, err)
+		return nil, fmt.Errorf("failed to parse jsonPath '%s': %w", jsonPath, err)
 	}
 
 	var jsonData interface{}
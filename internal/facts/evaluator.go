@@ -3,33 +3,72 @@ package facts
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/motain/compass-compute/internal/services"
 )
 
 type FactEvaluator struct {
 	repoPath          string
-	prometheusService *services.PrometheusService
+	repoRoots         map[string]string
+	prometheusService services.PrometheusServiceInterface
+	httpClient        *http.Client
+	at                time.Time
 }
 
-func NewFactEvaluator(repoPath string) *FactEvaluator {
+// Options tunes a metric evaluation.
+type Options struct {
+	// BasePath is the directory repositories are checked out under
+	BasePath string
+	// RepoRoots maps a repository name used in facts to the directory holding
+	// its sources, e.g. a sub-directory of a monorepo checkout. Repositories
+	// not listed are looked up under the local base path.
+	RepoRoots map[string]string
+	// Prometheus answers prometheus facts; connects lazily to the configured
+	// workspace when nil
+	Prometheus services.PrometheusServiceInterface
+	// HTTPClient fetches api facts; services.NewHTTPClient when nil
+	HTTPClient *http.Client
+	// At is the time prometheus facts are evaluated at; now when zero
+	At time.Time
+}
 
-	client := services.NewPrometheusClient()
-	prometheusService := services.NewPrometheusService(client)
+func NewFactEvaluator(repoPath string, opts Options) *FactEvaluator {
+	prometheusService := opts.Prometheus
+	if prometheusService == nil {
+		prometheusService = services.NewLazyPrometheusService()
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = services.NewHTTPClient()
+	}
 
 	return &FactEvaluator{
 		repoPath:          repoPath,
+		repoRoots:         opts.RepoRoots,
 		prometheusService: prometheusService,
+		httpClient:        httpClient,
+		at:                opts.At,
+	}
+}
+
+// now returns the time the evaluation is made for.
+func (fe *FactEvaluator) now() time.Time {
+	if fe.at.IsZero() {
+		return time.Now()
 	}
+	return fe.at
 }
 
-func EvaluateMetric(facts []services.Fact, componentName string) (interface{}, error) {
+func EvaluateMetric(facts []services.Fact, componentName string, opts Options) (interface{}, error) {
 	if len(facts) == 0 {
 		return nil, fmt.Errorf("no facts provided")
 	}
 
-	evaluator := NewFactEvaluator("./repos")
+	evaluator := NewFactEvaluator(opts.BasePath, opts)
 	ctx := context.Background()
 
 	factMap := make(map[string]*services.Fact)
@@ -55,7 +94,7 @@ func EvaluateMetric(facts []services.Fact, componentName string) (interface{}, e
 			}
 
 			if err := evaluator.processFact(ctx, fact, factMap); err != nil {
-				return nil, fmt.Errorf("failed to process fact %s: %w", fact.ID, err)
+				return nil, fmt.Errorf("%s: failed to process fact %s: %w", fact.Pos, fact.ID, err)
 			}
 
 			fact.Done = true
@@ -75,7 +114,13 @@ func EvaluateMetric(facts []services.Fact, componentName string) (interface{}, e
 		}
 
 		if !progress {
-			return nil, fmt.Errorf("circular dependency or unresolved dependencies detected")
+			var pending []string
+			for i := range facts {
+				if !facts[i].Done {
+					pending = append(pending, fmt.Sprintf("%s (%s)", facts[i].ID, facts[i].Pos))
+				}
+			}
+			return nil, fmt.Errorf("circular dependency or unresolved dependencies detected for facts: %s", strings.Join(pending, ", "))
 		}
 	}
 
@@ -94,6 +139,14 @@ func EvaluateMetric(facts []services.Fact, componentName string) (interface{}, e
 	return finalResult, nil
 }
 
+// repoDir returns the local directory holding the sources of repo.
+func (fe *FactEvaluator) repoDir(repo string) string {
+	if root, ok := fe.repoRoots[repo]; ok {
+		return root
+	}
+	return filepath.Join(fe.repoPath, repo)
+}
+
 func (fe *FactEvaluator) processFact(ctx context.Context, fact *services.Fact, factMap map[string]*services.Fact) error {
 	if fact.Type == "" {
 		return fmt.Errorf("fact type is empty for fact ID: %s", fact.ID)
@@ -48,7 +48,7 @@ func (fe *FactEvaluator) extractFromGitHub(fact *services.Fact) ([]byte, error)
 		return nil, fmt.Errorf("filePath is required for GitHub source")
 	}
 
-	repoPath := filepath.Join(fe.repoPath, fact.Repo)
+	repoPath := fe.repoDir(fact.Repo)
 	filePath := filepath.Join(repoPath, fact.FilePath)
 
 	if filePath == repoPath {
@@ -71,7 +71,7 @@ func (fe *FactEvaluator) extractFromGitHub(fact *services.Fact) ([]byte, error)
 }
 
 func (fe *FactEvaluator) extractFromAPI(ctx context.Context, fact *services.Fact, factMap map[string]*services.Fact) ([]byte, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := fe.httpClient
 
 	uri := fe.substituteDependencyValues(fact, factMap)
 
@@ -94,15 +94,8 @@ func (fe *FactEvaluator) extractFromAPI(ctx context.Context, fact *services.Fact
 	}
 
 	// Add authentication if provided
-	if fact.Auth != nil {
-		if authMap, ok := fact.Auth.(map[string]interface{}); ok {
-			if header, exists := authMap["header"].(string); exists {
-				if tokenVar, exists := authMap["tokenVar"].(string); exists {
-					token := os.Getenv(tokenVar)
-					req.Header.Set(header, token)
-				}
-			}
-		}
+	if fact.Auth != nil && fact.Auth.Header != "" && fact.Auth.TokenVar != "" {
+		req.Header.Set(fact.Auth.Header, os.Getenv(fact.Auth.TokenVar))
 	}
 
 	resp, err := client.Do(req)
@@ -131,9 +124,9 @@ func (fe *FactEvaluator) extractFromPrometheus(fact *services.Fact) ([]byte, err
 
 	switch fact.Rule {
 	case "range":
-		start := time.Now().Add(-1 * time.Hour) // Default to 1 hour ago
-		end := time.Now()
-		step := 15 * time.Second // Default 15-second step
+		end := fe.now()
+		start := end.Add(-1 * time.Hour) // Default to 1 hour ago
+		step := 15 * time.Second         // Default 15-second step
 		result, err := fe.prometheusService.RangeQuery(query, start, end, step)
 		if err != nil {
 			return nil, fmt.Errorf("prometheus range query failed: %w", err)
@@ -141,13 +134,13 @@ func (fe *FactEvaluator) extractFromPrometheus(fact *services.Fact) ([]byte, err
 		return json.Marshal(result)
 	case "instant", "":
 		// Default to instant query
-		result, err := fe.prometheusService.InstantQuery(query)
+		result, err := fe.prometheusService.InstantQuery(query, fe.at)
 		if err != nil {
 			return nil, fmt.Errorf("prometheus instant query failed: %w", err)
 		}
 		response := map[string]interface{}{
 			"value":     result,
-			"timestamp": time.Now().Unix(),
+			"timestamp": fe.now().Unix(),
 			"query":     query,
 		}
 
@@ -159,7 +152,7 @@ func (fe *FactEvaluator) extractFromPrometheus(fact *services.Fact) ([]byte, err
 }
 
 func (fe *FactEvaluator) searchInRepo(repo, searchString string) ([]byte, error) {
-	repoPath := filepath.Join(fe.repoPath, repo)
+	repoPath := fe.repoDir(repo)
 	found := false
 
 	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
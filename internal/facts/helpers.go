@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/motain/compass-compute/internal/services"
 	"github.com/pelletier/go-toml/v2"
@@ -17,9 +18,7 @@ func replacePlaceholders(fact services.Fact, componentName string) services.Fact
 	fact.URI = pattern.ReplaceAllString(fact.URI, componentName)
 	fact.PrometheusQuery = pattern.ReplaceAllString(fact.PrometheusQuery, componentName)
 
-	if jsonPath, ok := fact.JSONPath.(string); ok {
-		fact.JSONPath = pattern.ReplaceAllString(jsonPath, componentName)
-	}
+	fact.JSONPath = pattern.ReplaceAllString(fact.JSONPath, componentName)
 
 	return fact
 }
@@ -54,3 +53,19 @@ func convertTOMLToJSON(tomlData []byte) ([]byte, error) {
 	// Convert to JSON while preserving nested structure
 	return json.Marshal(config)
 }
+
+// RepoPaths returns the files the facts read from repo once placeholders are
+// resolved for componentName. full is true when a fact needs the whole tree.
+func RepoPaths(facts []services.Fact, repo, componentName string) (paths []string, full bool) {
+	for _, fact := range facts {
+		fact = replacePlaceholders(fact, componentName)
+		if !strings.EqualFold(fact.Source, "github") || fact.Repo != repo {
+			continue
+		}
+		if fact.Rule == "search" || fact.FilePath == "" {
+			return nil, true
+		}
+		paths = append(paths, fact.FilePath)
+	}
+	return paths, false
+}
@@ -0,0 +1,138 @@
+package facts
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Unit kinds a metric format can declare.
+const (
+	UnitPercentage = "percentage"
+	UnitCount      = "count"
+	UnitDuration   = "duration"
+	UnitBoolean    = "boolean"
+	UnitNumber     = "number" // any other unit
+)
+
+// durationUnits maps duration unit spellings to their length.
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second, "duration": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// defaultPrecision is the number of decimals kept per unit kind.
+var defaultPrecision = map[string]int{
+	UnitPercentage: 2,
+	UnitCount:      0,
+	UnitDuration:   2,
+	UnitBoolean:    0,
+	UnitNumber:     6,
+}
+
+// UnitKind classifies the unit of a metric format.
+func UnitKind(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	switch unit {
+	case "%", "percent", "percentage":
+		return UnitPercentage
+	case "count", "#", "items", "number of":
+		return UnitCount
+	case "bool", "boolean", "yes/no":
+		return UnitBoolean
+	}
+	if _, ok := durationUnits[unit]; ok {
+		return UnitDuration
+	}
+	return UnitNumber
+}
+
+// ConvertValue turns the result of a metric evaluation into the number
+// submitted to Compass, according to the unit of format, and renders it with
+// the unit's precision and without exponent notation. Results that are not a
+// single value are errors.
+func ConvertValue(result interface{}, format services.MetricFormat) (float64, string, error) {
+	kind := UnitKind(format.Unit)
+
+	value, err := scalar(result, kind, format.Unit)
+	if err != nil {
+		return 0, "", err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, "", fmt.Errorf("metric value %v is not a finite number", value)
+	}
+	if kind == UnitBoolean && value != 0 && value != 1 {
+		return 0, "", fmt.Errorf("boolean metric value must be 0 or 1, got %v", value)
+	}
+
+	precision := defaultPrecision[kind]
+	if format.Precision != nil {
+		precision = *format.Precision
+	}
+	scale := math.Pow(10, float64(precision))
+	value = math.Round(value*scale) / scale
+	if value == 0 {
+		value = 0 // no "-0"
+	}
+	return value, strconv.FormatFloat(value, 'f', -1, 64), nil
+}
+
+// scalar reads a number from result, accepting the textual forms of its unit.
+func scalar(result interface{}, kind, unit string) (float64, error) {
+	switch v := result.(type) {
+	case nil:
+		return 0, fmt.Errorf("metric produced no value")
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return parseScalar(strings.TrimSpace(v), kind, unit)
+	}
+
+	if number := convertToFloat64(result); number != nil {
+		return *number, nil
+	}
+
+	switch reflect.ValueOf(result).Kind() {
+	case reflect.Slice, reflect.Array:
+		return 0, fmt.Errorf("metric result is a list of %d elements, not a single value; reduce it with a jsonPath such as 'length' or an aggregate fact", reflect.ValueOf(result).Len())
+	case reflect.Map, reflect.Struct:
+		return 0, fmt.Errorf("metric result is an object, not a single value; select a field with a jsonPath")
+	}
+	return 0, fmt.Errorf("metric result of type %T is not a number", result)
+}
+
+func parseScalar(text, kind, unit string) (float64, error) {
+	switch strings.ToLower(text) {
+	case "true", "yes":
+		return 1, nil
+	case "false", "no":
+		return 0, nil
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number, nil
+	}
+
+	switch kind {
+	case UnitPercentage:
+		if number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, "%")), 64); err == nil {
+			return number, nil
+		}
+	case UnitDuration:
+		// Durations such as "1h30m" are expressed in the metric's unit
+		if d, err := time.ParseDuration(text); err == nil {
+			return float64(d) / float64(durationUnits[strings.ToLower(strings.TrimSpace(unit))]), nil
+		}
+	}
+	return 0, fmt.Errorf("metric result '%s' is not a number", text)
+}
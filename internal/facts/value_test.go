@@ -0,0 +1,71 @@
+package facts
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+func TestConvertValue(t *testing.T) {
+	precision := func(p int) *int { return &p }
+
+	tests := []struct {
+		name   string
+		result interface{}
+		format services.MetricFormat
+		want   string
+		err    string // contained in the error, empty when none is expected
+	}{
+		{name: "true", result: true, want: "1"},
+		{name: "false", result: false, format: services.MetricFormat{Unit: "%"}, want: "0"},
+		{name: "boolean string", result: "yes", format: services.MetricFormat{Unit: "boolean"}, want: "1"},
+		{name: "boolean out of range", result: 2, format: services.MetricFormat{Unit: "boolean"}, err: "must be 0 or 1"},
+		{name: "percentage string", result: "85%", format: services.MetricFormat{Unit: "%"}, want: "85"},
+		{name: "percentage rounding", result: 83.456, format: services.MetricFormat{Unit: "percent"}, want: "83.46"},
+		{name: "duration in minutes", result: "1h30m", format: services.MetricFormat{Unit: "min"}, want: "90"},
+		{name: "duration in hours", result: "45m", format: services.MetricFormat{Unit: "h"}, want: "0.75"},
+		{name: "count rounding", result: 2.6, format: services.MetricFormat{Unit: "count"}, want: "3"},
+		{name: "count string", result: "41.4", format: services.MetricFormat{Unit: "count"}, want: "41"},
+		{name: "number default precision", result: 1.23456789, want: "1.234568"},
+		{name: "precision override", result: 83.456, format: services.MetricFormat{Unit: "%", Precision: precision(0)}, want: "83"},
+		{name: "precision override on count", result: 2.75, format: services.MetricFormat{Unit: "count", Precision: precision(1)}, want: "2.8"},
+		{name: "no exponent", result: 12345678912.0, want: "12345678912"},
+		{name: "negative zero", result: -0.0001, format: services.MetricFormat{Unit: "count"}, want: "0"},
+		{name: "NaN", result: math.NaN(), err: "not a finite number"},
+		{name: "Inf", result: math.Inf(1), err: "not a finite number"},
+		{name: "nil", result: nil, err: "no value"},
+		{name: "list", result: []interface{}{1, 2, 3}, err: "list of 3 elements"},
+		{name: "object", result: map[string]interface{}{"pct": 80}, err: "is an object"},
+		{name: "text", result: "high", format: services.MetricFormat{Unit: "%"}, err: "'high' is not a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := ConvertValue(tt.result, tt.format)
+			switch {
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("ConvertValue(%v) error = %v, want one containing %q", tt.result, err, tt.err)
+			case tt.err == "" && err != nil:
+				t.Errorf("ConvertValue(%v) failed: %v", tt.result, err)
+			case got != tt.want:
+				t.Errorf("ConvertValue(%v) = %s, want %s", tt.result, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitKind(t *testing.T) {
+	kinds := map[string]string{
+		"%": UnitPercentage, " Percent ": UnitPercentage,
+		"count": UnitCount, "#": UnitCount,
+		"ms": UnitDuration, "Days": UnitDuration,
+		"bool":  UnitBoolean,
+		"req/s": UnitNumber, "": UnitNumber,
+	}
+	for unit, want := range kinds {
+		if got := UnitKind(unit); got != want {
+			t.Errorf("UnitKind(%q) = %s, want %s", unit, got, want)
+		}
+	}
+}
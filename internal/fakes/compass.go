@@ -0,0 +1,435 @@
+// Package fakes provides in-memory stand-ins for the Compass API, Prometheus
+// and repository fetching, so runs can be exercised without network access.
+package fakes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Component is a Compass component with the attributes searches filter on.
+// Like Compass, the fake finds components by slug; Name is the display name.
+type Component struct {
+	services.Component
+	Slug    string // slug without ServiceSlugPrefix, Name when empty
+	Labels  []string
+	OwnerID string
+}
+
+func (c *Component) slug() string {
+	if c.Slug != "" {
+		return c.Slug
+	}
+	return c.Name
+}
+
+// Submission is a metric value received by PutMetrics.
+type Submission struct {
+	ComponentID        string
+	MetricDefinitionID string
+	Value              string
+	Time               time.Time
+}
+
+// Compass is an in-memory services.CompassClient. It is safe for concurrent use.
+type Compass struct {
+	mu          sync.Mutex
+	components  []*Component
+	definitions []services.CompassMetricDefinition
+	scorecards  []services.CompassScorecard
+	teams       []services.Team
+	submissions []Submission
+	nextID      int
+
+	// FailSources maps metric source IDs to the error PutMetrics returns for them
+	FailSources map[string]error
+}
+
+var _ services.CompassClient = (*Compass)(nil)
+
+func NewCompass() *Compass {
+	return &Compass{FailSources: make(map[string]error)}
+}
+
+func (c *Compass) id(kind string) string {
+	c.nextID++
+	return fmt.Sprintf("%s-%d", kind, c.nextID)
+}
+
+// AddComponent registers a component, assigning an ID when it has none.
+func (c *Compass) AddComponent(component Component) *Component {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if component.ID == "" {
+		component.ID = c.id("component")
+	}
+	c.components = append(c.components, &component)
+	return &component
+}
+
+// AddTeam registers a team and returns its ID.
+func (c *Compass) AddTeam(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := "ari:cloud:identity::team/" + c.id("team")
+	c.teams = append(c.teams, services.Team{ID: id, Name: name})
+	return id
+}
+
+// AddMetricDefinition registers a metric definition, assigning an ID when it
+// has none, and returns the ID.
+func (c *Compass) AddMetricDefinition(definition services.CompassMetricDefinition) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if definition.ID == "" {
+		definition.ID = c.id("metric-definition")
+	}
+	c.definitions = append(c.definitions, definition)
+	return definition.ID
+}
+
+// AttachMetric adds a metric source for the named definition to the component
+// with the given slug and returns the source ID.
+func (c *Compass) AttachMetric(componentName, metricName string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	component := c.component(componentName)
+	if component == nil {
+		return "", fmt.Errorf("component not found: %s", componentName)
+	}
+	for _, definition := range c.definitions {
+		if definition.Name == metricName {
+			return c.attach(component, definition), nil
+		}
+	}
+	return "", fmt.Errorf("metric definition not found: %s", metricName)
+}
+
+func (c *Compass) attach(component *Component, definition services.CompassMetricDefinition) string {
+	sourceID := c.id("metric-source")
+	component.Metrics = append(component.Metrics, services.Metric{
+		Name:         definition.Name,
+		DefinitionID: definition.ID,
+		SourceID:     sourceID,
+	})
+	return sourceID
+}
+
+func (c *Compass) component(slug string) *Component {
+	for _, component := range c.components {
+		if component.slug() == slug {
+			return component
+		}
+	}
+	return nil
+}
+
+// Submissions returns the metric values received so far, oldest first.
+func (c *Compass) Submissions() []Submission {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Submission(nil), c.submissions...)
+}
+
+func (c *Compass) GetComponent(name string) (*services.Component, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	component := c.component(name)
+	if component == nil {
+		return nil, fmt.Errorf("component not found: %s", name)
+	}
+	result := component.Component
+	result.Name = name
+	result.Metrics = append([]services.Metric(nil), component.Metrics...)
+	if result.Repository == nil {
+		result.Repository = &services.GitInfo{Host: services.DefaultGitHost, Owner: services.CurrentConfig().GitHubOrg, Repo: name}
+	}
+	return &result, nil
+}
+
+func (c *Compass) ListComponents(filter services.ComponentFilter) ([]services.Component, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var components []services.Component
+	for _, component := range c.components {
+		if matches(component, filter) {
+			result := component.Component
+			result.Name = component.slug()
+			result.Metrics = append([]services.Metric(nil), component.Metrics...)
+			result.Repository = nil // searches do not return links or values
+			for i := range result.Metrics {
+				result.Metrics[i].Latest = nil
+			}
+			components = append(components, result)
+		}
+	}
+	return components, nil
+}
+
+// displayName returns the name Compass shows for the component with slug.
+func (c *Compass) displayName(slug string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if component := c.component(slug); component != nil {
+		return component.Name
+	}
+	return slug
+}
+
+// SearchTeams returns the teams whose name contains term, ignoring case.
+func (c *Compass) SearchTeams(term string) ([]services.Team, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var teams []services.Team
+	for _, team := range c.teams {
+		if strings.Contains(strings.ToLower(team.Name), strings.ToLower(term)) {
+			teams = append(teams, team)
+		}
+	}
+	return teams, nil
+}
+
+func matches(component *Component, filter services.ComponentFilter) bool {
+	if len(filter.Types) > 0 {
+		found := false
+		for _, t := range filter.Types {
+			found = found || strings.EqualFold(t, component.Type)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, label := range filter.Labels {
+		found := false
+		for _, l := range component.Labels {
+			found = found || l == label
+		}
+		if !found {
+			return false
+		}
+	}
+	return filter.OwnerID == "" || filter.OwnerID == component.OwnerID
+}
+
+// PutMetrics stores each submission by metric source; FailSources makes
+// chosen sources reject values, to exercise partial failures.
+func (c *Compass) PutMetrics(submissions []services.MetricSubmission) []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]error, len(submissions))
+	for n, submission := range submissions {
+		errs[n] = c.putSource(submission)
+	}
+	return errs
+}
+
+func (c *Compass) putSource(submission services.MetricSubmission) error {
+	if err, ok := c.FailSources[submission.MetricSourceID]; ok {
+		return err
+	}
+	for _, component := range c.components {
+		for i, metric := range component.Metrics {
+			if metric.SourceID == submission.MetricSourceID {
+				return c.put(component, i, submission.Value, submission.Timestamp)
+			}
+		}
+	}
+	return fmt.Errorf("metric source not found: %s", submission.MetricSourceID)
+}
+
+func (c *Compass) put(component *Component, i int, value string, timestamp time.Time) error {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("API error 400: value '%s' is not a number", value)
+	}
+	metric := component.Metrics[i]
+	c.submissions = append(c.submissions, Submission{
+		ComponentID:        component.ID,
+		MetricDefinitionID: metric.DefinitionID,
+		Value:              value,
+		Time:               timestamp,
+	})
+	// Backdated values do not replace a newer latest value
+	if latest := component.Metrics[i].Latest; latest == nil || !timestamp.Before(latest.Timestamp) {
+		component.Metrics[i].Latest = &services.MetricValue{Value: number, Timestamp: timestamp}
+	}
+	return nil
+}
+
+func (c *Compass) GetMetricDefinitions() ([]services.CompassMetricDefinition, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]services.CompassMetricDefinition(nil), c.definitions...), nil
+}
+
+func (c *Compass) CreateMetricDefinition(definition services.CompassMetricDefinition) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.definitions {
+		if existing.Name == definition.Name {
+			return "", fmt.Errorf("failed to create metric definition '%s': name already in use", definition.Name)
+		}
+	}
+	definition.ID = c.id("metric-definition")
+	c.definitions = append(c.definitions, definition)
+	return definition.ID, nil
+}
+
+func (c *Compass) UpdateMetricDefinition(definition services.CompassMetricDefinition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.definitions {
+		if existing.ID == definition.ID {
+			definition.BuiltIn = existing.BuiltIn
+			c.definitions[i] = definition
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to update metric definition '%s': not found", definition.Name)
+}
+
+func (c *Compass) CreateMetricSource(componentID, metricDefinitionID string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, component := range c.components {
+		if component.ID != componentID {
+			continue
+		}
+		for _, definition := range c.definitions {
+			if definition.ID == metricDefinitionID {
+				return c.attach(component, definition), nil
+			}
+		}
+		return "", fmt.Errorf("failed to create metric source: metric definition not found: %s", metricDefinitionID)
+	}
+	return "", fmt.Errorf("failed to create metric source: component not found: %s", componentID)
+}
+
+func (c *Compass) DeleteMetricSource(sourceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, component := range c.components {
+		for i, metric := range component.Metrics {
+			if metric.SourceID == sourceID {
+				component.Metrics = append(component.Metrics[:i], component.Metrics[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("failed to delete metric source: not found: %s", sourceID)
+}
+
+func (c *Compass) GetScorecards() ([]services.CompassScorecard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scorecards := make([]services.CompassScorecard, len(c.scorecards))
+	for i, scorecard := range c.scorecards {
+		scorecard.Criteria = append([]services.CompassScorecardCriterion(nil), scorecard.Criteria...)
+		scorecards[i] = scorecard
+	}
+	return scorecards, nil
+}
+
+// CreateScorecard checks what Compass checks: unique names, known metric
+// definitions and weights adding up to 100.
+func (c *Compass) CreateScorecard(scorecard services.CompassScorecard) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.scorecards {
+		if existing.Name == scorecard.Name {
+			return "", fmt.Errorf("failed to create scorecard '%s': name already in use", scorecard.Name)
+		}
+	}
+	scorecard.Criteria = append([]services.CompassScorecardCriterion(nil), scorecard.Criteria...)
+	for i := range scorecard.Criteria {
+		scorecard.Criteria[i].ID = c.id("criterion")
+	}
+	if err := c.checkScorecard(scorecard); err != nil {
+		return "", fmt.Errorf("failed to create scorecard '%s': %w", scorecard.Name, err)
+	}
+	scorecard.ID = c.id("scorecard")
+	c.scorecards = append(c.scorecards, scorecard)
+	return scorecard.ID, nil
+}
+
+func (c *Compass) UpdateScorecard(scorecard services.CompassScorecard, removedCriteria []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.scorecards {
+		if existing.ID != scorecard.ID {
+			continue
+		}
+		removed := make(map[string]bool)
+		for _, id := range removedCriteria {
+			removed[id] = true
+		}
+		var criteria []services.CompassScorecardCriterion
+		index := make(map[string]int)
+		for _, criterion := range existing.Criteria {
+			if !removed[criterion.ID] {
+				index[criterion.ID] = len(criteria)
+				criteria = append(criteria, criterion)
+			}
+		}
+		for _, criterion := range scorecard.Criteria {
+			if criterion.ID == "" {
+				criterion.ID = c.id("criterion")
+				criteria = append(criteria, criterion)
+				continue
+			}
+			j, ok := index[criterion.ID]
+			if !ok {
+				return fmt.Errorf("failed to update scorecard '%s': criterion not found: %s", scorecard.Name, criterion.ID)
+			}
+			criteria[j] = criterion
+		}
+
+		updated := scorecard
+		updated.Criteria = criteria
+		if err := c.checkScorecard(updated); err != nil {
+			return fmt.Errorf("failed to update scorecard '%s': %w", scorecard.Name, err)
+		}
+		c.scorecards[i] = updated
+		return nil
+	}
+	return fmt.Errorf("failed to update scorecard '%s': not found", scorecard.Name)
+}
+
+func (c *Compass) checkScorecard(scorecard services.CompassScorecard) error {
+	total := 0
+	for _, criterion := range scorecard.Criteria {
+		total += criterion.Weight
+		known := false
+		for _, definition := range c.definitions {
+			known = known || definition.ID == criterion.MetricDefinitionID
+		}
+		if !known {
+			return fmt.Errorf("metric definition not found: %s", criterion.MetricDefinitionID)
+		}
+	}
+	if total != 100 {
+		return fmt.Errorf("criteria weights add up to %d, not 100", total)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package fakes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// DefaultCommit is the commit SHA reported for repositories without one.
+const DefaultCommit = "0000000000000000000000000000000000000000"
+
+// Repository is the file tree of a fake repository, keyed by slash separated
+// path relative to the repository root.
+type Repository struct {
+	Files  map[string]string
+	Commit string
+}
+
+// RepoFetcher checks out in-memory repositories, keyed by "owner/repo".
+type RepoFetcher struct {
+	Repositories map[string]Repository
+}
+
+var _ services.RepoFetcher = (*RepoFetcher)(nil)
+
+func NewRepoFetcher() *RepoFetcher {
+	return &RepoFetcher{Repositories: make(map[string]Repository)}
+}
+
+// Add registers a repository with the given files and returns it.
+func (rf *RepoFetcher) Add(owner, repo string, files map[string]string) Repository {
+	repository := Repository{Files: files, Commit: DefaultCommit}
+	rf.Repositories[owner+"/"+repo] = repository
+	return repository
+}
+
+// Clone writes the files of the repository below repoPath. Like the sparse
+// fetchers, only files under paths are written when paths is non-empty.
+func (rf *RepoFetcher) Clone(info *services.GitInfo, repoPath string, paths []string) (string, error) {
+	repository, ok := rf.Repositories[info.Owner+"/"+info.Repo]
+	if !ok {
+		return "", fmt.Errorf("repository not found: %s/%s", info.Owner, info.Repo)
+	}
+
+	if err := os.RemoveAll(repoPath); err != nil {
+		return "", fmt.Errorf("failed to clean up existing directory: %w", err)
+	}
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	files := make(map[string]string)
+	for name, content := range repository.Files {
+		if wanted(name, paths) {
+			files[name] = content
+		}
+	}
+	if err := WriteTree(repoPath, files); err != nil {
+		return "", err
+	}
+
+	if repository.Commit == "" {
+		return DefaultCommit, nil
+	}
+	return repository.Commit, nil
+}
+
+// WriteTree writes files, keyed by slash separated path, below dir.
+func WriteTree(dir string, files map[string]string) error {
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func wanted(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p == "" || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package fakes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Response is a canned HTTP response.
+type Response struct {
+	Status int // 200 when zero
+	Body   string
+}
+
+// Transport answers requests from canned responses keyed by URL. Requests
+// without a response fail, so a fixture cannot silently hit the network.
+type Transport struct {
+	Responses map[string]Response
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+func NewTransport() *Transport {
+	return &Transport{Responses: make(map[string]Response)}
+}
+
+// Client returns an HTTP client using the transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, ok := t.Responses[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("no canned response for %s %s", req.Method, req.URL)
+	}
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response.Body)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package fakes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/prometheus/common/model"
+)
+
+// Prometheus answers queries from canned results keyed by the query string.
+// The evaluation times of the queries are kept in Times.
+type Prometheus struct {
+	Instant map[string]float64
+	Range   map[string]model.Value
+	Times   []time.Time
+}
+
+var _ services.PrometheusServiceInterface = (*Prometheus)(nil)
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		Instant: make(map[string]float64),
+		Range:   make(map[string]model.Value),
+	}
+}
+
+func (p *Prometheus) InstantQuery(queryString string, at time.Time) (float64, error) {
+	p.Times = append(p.Times, at)
+	value, ok := p.Instant[queryString]
+	if !ok {
+		return 0, fmt.Errorf("no result for query: %s", queryString)
+	}
+	return value, nil
+}
+
+func (p *Prometheus) RangeQuery(queryString string, start, end time.Time, step time.Duration) (model.Value, error) {
+	p.Times = append(p.Times, end)
+	value, ok := p.Range[queryString]
+	if !ok {
+		return nil, fmt.Errorf("no result for range query: %s", queryString)
+	}
+	return value, nil
+}
@@ -0,0 +1,357 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// operationName matches the name of a GraphQL query or mutation.
+var operationName = regexp.MustCompile(`(?:query|mutation)\s+(\w+)`)
+
+// insertAlias matches an aliased insertMetricValue field and its input variable.
+var insertAlias = regexp.MustCompile(`(\w+):\s*insertMetricValue\(input:\s*\$(\w+)\)`)
+
+// NewCompassServer starts an HTTP server that speaks the subset of the Compass
+// GraphQL API used by services.CompassService, backed by compass.
+// Point Config.CompassBaseURL at the server's URL. Callers must Close it.
+func NewCompassServer(compass *Compass) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string          `json:"query"`
+			Variables json.RawMessage `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		match := operationName.FindStringSubmatch(request.Query)
+		if match == nil {
+			http.Error(w, "missing operation name", http.StatusBadRequest)
+			return
+		}
+		var data map[string]interface{}
+		var err error
+		switch match[1] {
+		case "insertMetricValues":
+			data, err = insertMetricValues(compass, request.Query, request.Variables)
+		case "scorecards", "createScorecard", "updateScorecard":
+			data, err = scorecardOperation(compass, match[1], request.Variables)
+		default:
+			data, err = graphqlOperation(compass, match[1], request.Query, request.Variables)
+		}
+		if err != nil {
+			writeJSON(w, map[string]interface{}{"errors": []graphqlError{{Message: err.Error()}}})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"compass": data}})
+	})
+	return httptest.NewServer(mux)
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// mutationResult is the payload of a mutation, with the error in the body as
+// Compass reports it.
+func mutationResult(err error, fields map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"success": err == nil, "errors": []graphqlError{}}
+	if err != nil {
+		result["errors"] = []graphqlError{{Message: err.Error()}}
+		return result
+	}
+	for k, v := range fields {
+		result[k] = v
+	}
+	return result
+}
+
+func graphqlOperation(compass *Compass, operation, query string, raw json.RawMessage) (map[string]interface{}, error) {
+	var variables struct {
+		Slug  string `json:"slug"`
+		Term  string `json:"term"`
+		Query struct {
+			FieldFilters []struct {
+				Name   string `json:"name"`
+				Filter struct {
+					Eq string   `json:"eq"`
+					In []string `json:"in"`
+				} `json:"filter"`
+			} `json:"fieldFilters"`
+		} `json:"query"`
+		Input struct {
+			ID                 string `json:"id"`
+			Name               string `json:"name"`
+			Description        string `json:"description"`
+			ComponentID        string `json:"componentId"`
+			MetricDefinitionID string `json:"metricDefinitionId"`
+			Format             struct {
+				Suffix struct {
+					Suffix string `json:"suffix"`
+				} `json:"suffix"`
+			} `json:"format"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(raw, &variables); err != nil {
+		return nil, fmt.Errorf("invalid variables: %w", err)
+	}
+	input := variables.Input
+
+	switch operation {
+	case "getComponent":
+		name := strings.TrimPrefix(variables.Slug, services.CurrentConfig().ServiceSlugPrefix)
+		component, err := compass.GetComponent(name)
+		if err != nil {
+			return map[string]interface{}{"componentByReference": nil}, nil
+		}
+		node := componentNode(compass, *component, strings.Contains(query, "values("))
+		node["links"] = []map[string]string{{"type": "REPOSITORY", "url": repositoryURL(component.Repository), "name": "repository"}}
+		return map[string]interface{}{"componentByReference": node}, nil
+
+	case "listComponents":
+		var filter services.ComponentFilter
+		for _, f := range variables.Query.FieldFilters {
+			switch f.Name {
+			case "type":
+				filter.Types = f.Filter.In
+			case "labels":
+				filter.Labels = append(filter.Labels, f.Filter.In...)
+			case "ownerId":
+				filter.OwnerID = f.Filter.Eq
+			}
+		}
+		components, _ := compass.ListComponents(filter)
+		nodes := make([]map[string]interface{}, len(components))
+		for i, component := range components {
+			nodes[i] = map[string]interface{}{"component": componentNode(compass, component, strings.Contains(query, "values("))}
+		}
+		return map[string]interface{}{"searchComponents": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": map[string]interface{}{"hasNextPage": false},
+		}}, nil
+
+	case "searchTeams":
+		teams, _ := compass.SearchTeams(variables.Term)
+		nodes := make([]map[string]interface{}, len(teams))
+		for i, team := range teams {
+			nodes[i] = map[string]interface{}{"team": map[string]string{"id": team.ID, "displayName": team.Name}}
+		}
+		return map[string]interface{}{"searchTeams": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": map[string]interface{}{"hasNextPage": false},
+		}}, nil
+
+	case "metricDefinitions":
+		definitions, _ := compass.GetMetricDefinitions()
+		nodes := make([]map[string]interface{}, len(definitions))
+		for i, definition := range definitions {
+			definitionType := "USER_DEFINED"
+			if definition.BuiltIn {
+				definitionType = "BUILT_IN"
+			}
+			nodes[i] = map[string]interface{}{
+				"id":          definition.ID,
+				"name":        definition.Name,
+				"description": definition.Description,
+				"type":        definitionType,
+				"format":      map[string]string{"suffix": definition.Unit},
+			}
+		}
+		return map[string]interface{}{"metricDefinitions": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": map[string]interface{}{"hasNextPage": false},
+		}}, nil
+
+	case "createMetricDefinition":
+		id, err := compass.CreateMetricDefinition(services.CompassMetricDefinition{
+			Name: input.Name, Description: input.Description, Unit: input.Format.Suffix.Suffix,
+		})
+		return map[string]interface{}{"createMetricDefinition": mutationResult(err, map[string]interface{}{
+			"createdMetricDefinition": map[string]string{"id": id, "name": input.Name},
+		})}, nil
+
+	case "updateMetricDefinition":
+		err := compass.UpdateMetricDefinition(services.CompassMetricDefinition{
+			ID: input.ID, Name: input.Name, Description: input.Description, Unit: input.Format.Suffix.Suffix,
+		})
+		return map[string]interface{}{"updateMetricDefinition": mutationResult(err, map[string]interface{}{
+			"updatedMetricDefinition": map[string]string{"id": input.ID, "name": input.Name},
+		})}, nil
+
+	case "createMetricSource":
+		id, err := compass.CreateMetricSource(input.ComponentID, input.MetricDefinitionID)
+		return map[string]interface{}{"createMetricSource": mutationResult(err, map[string]interface{}{
+			"createdMetricSource": map[string]string{"id": id},
+		})}, nil
+
+	case "deleteMetricSource":
+		err := compass.DeleteMetricSource(input.ID)
+		return map[string]interface{}{"deleteMetricSource": mutationResult(err, nil)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operation: %s", operation)
+}
+
+// insertMetricValues answers each aliased insertMetricValue of a batch.
+func insertMetricValues(compass *Compass, query string, raw json.RawMessage) (map[string]interface{}, error) {
+	var variables map[string]struct {
+		MetricSourceID string `json:"metricSourceId"`
+		Value          struct {
+			Value     float64   `json:"value"`
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &variables); err != nil {
+		return nil, fmt.Errorf("invalid variables: %w", err)
+	}
+
+	data := make(map[string]interface{})
+	for _, match := range insertAlias.FindAllStringSubmatch(query, -1) {
+		input, ok := variables[match[2]]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", match[2])
+		}
+		errs := compass.PutMetrics([]services.MetricSubmission{{
+			MetricSourceID: input.MetricSourceID,
+			Value:          strconv.FormatFloat(input.Value.Value, 'f', -1, 64),
+			Timestamp:      input.Value.Timestamp,
+		}})
+		data[match[1]] = mutationResult(errs[0], nil)
+	}
+	return data, nil
+}
+
+func scorecardOperation(compass *Compass, operation string, raw json.RawMessage) (map[string]interface{}, error) {
+	type criterionInput struct {
+		HasMetricValue services.CompassScorecardCriterion `json:"hasMetricValue"`
+	}
+	var variables struct {
+		ScorecardID string `json:"scorecardId"`
+		Input       struct {
+			Name             string           `json:"name"`
+			Description      string           `json:"description"`
+			Importance       string           `json:"importance"`
+			ComponentTypeIDs []string         `json:"componentTypeIds"`
+			OwnerID          string           `json:"ownerId"`
+			Criterias        []criterionInput `json:"criterias"`
+			CreateCriteria   []criterionInput `json:"createCriteria"`
+			UpdateCriteria   []criterionInput `json:"updateCriteria"`
+			DeleteCriteria   []struct {
+				ID string `json:"id"`
+			} `json:"deleteCriteria"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(raw, &variables); err != nil {
+		return nil, fmt.Errorf("invalid variables: %w", err)
+	}
+	input := variables.Input
+	scorecard := services.CompassScorecard{
+		ID:             variables.ScorecardID,
+		Name:           input.Name,
+		Description:    input.Description,
+		Importance:     input.Importance,
+		ComponentTypes: input.ComponentTypeIDs,
+		OwnerID:        input.OwnerID,
+	}
+	for _, criteria := range [][]criterionInput{input.Criterias, input.UpdateCriteria, input.CreateCriteria} {
+		for _, criterion := range criteria {
+			scorecard.Criteria = append(scorecard.Criteria, criterion.HasMetricValue)
+		}
+	}
+
+	switch operation {
+	case "scorecards":
+		scorecards, _ := compass.GetScorecards()
+		nodes := make([]map[string]interface{}, len(scorecards))
+		for i, scorecard := range scorecards {
+			var owner interface{}
+			if scorecard.OwnerID != "" {
+				owner = map[string]string{"id": scorecard.OwnerID}
+			}
+			nodes[i] = map[string]interface{}{
+				"id":               scorecard.ID,
+				"name":             scorecard.Name,
+				"description":      scorecard.Description,
+				"importance":       scorecard.Importance,
+				"componentTypeIds": scorecard.ComponentTypes,
+				"owner":            owner,
+				"criterias":        scorecard.Criteria,
+			}
+		}
+		return map[string]interface{}{"scorecards": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": map[string]interface{}{"hasNextPage": false},
+		}}, nil
+
+	case "createScorecard":
+		id, err := compass.CreateScorecard(scorecard)
+		return map[string]interface{}{"createScorecard": mutationResult(err, map[string]interface{}{
+			"scorecardDetails": map[string]string{"id": id},
+		})}, nil
+
+	case "updateScorecard":
+		var removed []string
+		for _, criterion := range input.DeleteCriteria {
+			removed = append(removed, criterion.ID)
+		}
+		err := compass.UpdateScorecard(scorecard, removed)
+		return map[string]interface{}{"updateScorecard": mutationResult(err, nil)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operation: %s", operation)
+}
+
+// componentNode renders a component returned by compass as Compass does, with
+// its display name and slug. Like Compass, the latest metric values are only
+// included when the query selects them.
+func componentNode(compass *Compass, component services.Component, withValues bool) map[string]interface{} {
+	sources := make([]map[string]interface{}, len(component.Metrics))
+	for i, metric := range component.Metrics {
+		sources[i] = map[string]interface{}{
+			"id":               metric.SourceID,
+			"metricDefinition": map[string]string{"id": metric.DefinitionID, "name": metric.Name},
+		}
+		if withValues {
+			values := []services.MetricValue{}
+			if metric.Latest != nil {
+				values = append(values, *metric.Latest)
+			}
+			sources[i]["values"] = map[string]interface{}{"nodes": values}
+		}
+	}
+	return map[string]interface{}{
+		"id":            component.ID,
+		"name":          compass.displayName(component.Name),
+		"slug":          services.CurrentConfig().ServiceSlugPrefix + component.Name,
+		"type":          component.Type,
+		"metricSources": map[string]interface{}{"nodes": sources},
+	}
+}
+
+// repositoryURL renders info as the GitHub URL a REPOSITORY link holds.
+func repositoryURL(info *services.GitInfo) string {
+	url := fmt.Sprintf("https://%s/%s/%s", info.Host, info.Owner, info.Repo)
+	if info.Path != "" {
+		branch := info.Branch
+		if branch == "" {
+			branch = "main"
+		}
+		url += "/tree/" + branch + "/" + info.Path
+	}
+	return url
+}
@@ -0,0 +1,190 @@
+package fakes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// newService returns a CompassService talking to a fake server backed by compass.
+func newService(t *testing.T, compass *Compass) *services.CompassService {
+	t.Helper()
+	server := NewCompassServer(compass)
+	t.Cleanup(server.Close)
+
+	previous := services.CurrentConfig()
+	cfg := services.DefaultConfig()
+	cfg.CompassBaseURL = server.URL
+	cfg.CompassCloudID = "cloud-1"
+	cfg.CompassAPIToken = "token"
+	services.SetConfig(&cfg)
+	t.Cleanup(func() {
+		if previous != nil {
+			services.SetConfig(previous)
+		}
+	})
+	return services.NewCompassServiceWithClient(server.Client())
+}
+
+func TestCompassServiceComponents(t *testing.T) {
+	compass := NewCompass()
+	compass.AddComponent(Component{Component: services.Component{
+		ID:         "component-1",
+		Name:       "svc",
+		Type:       "SERVICE",
+		Repository: &services.GitInfo{Host: services.DefaultGitHost, Owner: "motain", Repo: "mono", Path: "services/svc"},
+	}})
+	compass.AddMetricDefinition(services.CompassMetricDefinition{Name: "test-coverage", Unit: "%"})
+	sourceID, err := compass.AttachMetric("svc", "test-coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newService(t, compass)
+
+	component, err := service.GetComponent("svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if component.ID != "component-1" || component.Type != "SERVICE" || len(component.Metrics) != 1 || component.Metrics[0].SourceID != sourceID || component.Metrics[0].Latest != nil {
+		t.Errorf("GetComponent() = %+v", component)
+	}
+	if repository := component.Repository; repository.Owner != "motain" || repository.Repo != "mono" || repository.Path != "services/svc" {
+		t.Errorf("GetComponent() repository = %+v", repository)
+	}
+	if _, err := service.GetComponent("missing"); err == nil {
+		t.Error("GetComponent() of an unknown component succeeded")
+	}
+
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	errs := service.PutMetrics([]services.MetricSubmission{
+		{ComponentID: "component-1", MetricSourceID: sourceID, Value: "85.5", Timestamp: at},
+		{ComponentID: "component-1", MetricSourceID: "unknown-source", Value: "1", Timestamp: at},
+	})
+	if len(errs) != 2 || errs[0] != nil || errs[1] == nil {
+		t.Fatalf("PutMetrics() = %v, want only the unknown source to fail", errs)
+	}
+	submissions := compass.Submissions()
+	if len(submissions) != 1 || submissions[0].Value != "85.5" || !submissions[0].Time.Equal(at) {
+		t.Errorf("Compass stored %+v", submissions)
+	}
+
+	component, err = service.GetComponent("svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := component.Metrics[0].Latest; latest == nil || latest.Value != 85.5 {
+		t.Errorf("latest value = %+v, want 85.5", latest)
+	}
+}
+
+func TestCompassServiceListComponents(t *testing.T) {
+	compass := NewCompass()
+	compass.AddComponent(Component{Component: services.Component{ID: "component-1", Name: "Payments API", Type: "SERVICE"}, Slug: "payments", Labels: []string{"tier-1"}})
+	compass.AddComponent(Component{Component: services.Component{ID: "component-2", Name: "search", Type: "SERVICE"}})
+	service := newService(t, compass)
+
+	components, err := service.ListComponents(services.ComponentFilter{Labels: []string{"tier-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Runs look components up by the name a search returns
+	if len(components) != 1 || components[0].Name != "payments" {
+		t.Fatalf("ListComponents() = %+v, want the payments component by slug", components)
+	}
+	if _, err := service.GetComponent(components[0].Name); err != nil {
+		t.Errorf("GetComponent() of a search result: %v", err)
+	}
+}
+
+func TestCompassServiceSearchTeams(t *testing.T) {
+	compass := NewCompass()
+	platform := compass.AddTeam("Platform")
+	compass.AddTeam("Payments")
+	service := newService(t, compass)
+
+	teams, err := service.SearchTeams("plat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(teams) != 1 || teams[0].ID != platform || teams[0].Name != "Platform" {
+		t.Errorf("SearchTeams() = %+v, want the Platform team", teams)
+	}
+}
+
+func TestCompassServiceMetricDefinitions(t *testing.T) {
+	compass := NewCompass()
+	service := newService(t, compass)
+
+	id, err := service.CreateMetricDefinition(services.CompassMetricDefinition{Name: "lead-time", Description: "Lead time", Unit: "days"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateMetricDefinition(services.CompassMetricDefinition{Name: "lead-time"}); err == nil {
+		t.Error("CreateMetricDefinition() of a duplicate name succeeded")
+	}
+
+	// An empty unit clears the suffix instead of keeping the old one
+	if err := service.UpdateMetricDefinition(services.CompassMetricDefinition{ID: id, Name: "lead-time", Description: "Lead time"}); err != nil {
+		t.Fatal(err)
+	}
+	definitions, err := service.GetMetricDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definitions) != 1 || definitions[0].ID != id || definitions[0].Unit != "" || definitions[0].Description != "Lead time" {
+		t.Errorf("GetMetricDefinitions() = %+v, want lead-time without unit", definitions)
+	}
+}
+
+func TestCompassServiceScorecards(t *testing.T) {
+	compass := NewCompass()
+	coverage := compass.AddMetricDefinition(services.CompassMetricDefinition{Name: "test-coverage"})
+	uptime := compass.AddMetricDefinition(services.CompassMetricDefinition{Name: "uptime"})
+	service := newService(t, compass)
+
+	scorecard := services.CompassScorecard{
+		Name:           "Production readiness",
+		Importance:     "REQUIRED",
+		ComponentTypes: []string{"SERVICE"},
+		Criteria: []services.CompassScorecardCriterion{
+			{Name: "coverage", Weight: 60, MetricDefinitionID: coverage, Comparator: "GREATER_THAN_OR_EQUAL_TO", ComparatorValue: 80},
+			{Name: "uptime", Weight: 40, MetricDefinitionID: uptime, Comparator: "GREATER_THAN_OR_EQUAL_TO", ComparatorValue: 99.5},
+		},
+	}
+	id, err := service.CreateScorecard(scorecard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := scorecard
+	invalid.Name = "Invalid"
+	invalid.Criteria = invalid.Criteria[:1]
+	if _, err := service.CreateScorecard(invalid); err == nil || !strings.Contains(err.Error(), "add up to 60") {
+		t.Errorf("CreateScorecard() error = %v, want one about the weights", err)
+	}
+
+	scorecards, err := service.GetScorecards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scorecards) != 1 || scorecards[0].ID != id || len(scorecards[0].Criteria) != 2 {
+		t.Fatalf("GetScorecards() = %+v", scorecards)
+	}
+
+	// Drop the uptime criterion and give its weight to coverage
+	updated := scorecards[0]
+	removed := updated.Criteria[1].ID
+	updated.Criteria = updated.Criteria[:1]
+	updated.Criteria[0].Weight = 100
+	if err := service.UpdateScorecard(updated, []string{removed}); err != nil {
+		t.Fatal(err)
+	}
+	scorecards, err = service.GetScorecards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if criteria := scorecards[0].Criteria; len(criteria) != 1 || criteria[0].Name != "coverage" || criteria[0].Weight != 100 {
+		t.Errorf("criteria after update = %+v", criteria)
+	}
+}
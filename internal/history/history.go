@@ -0,0 +1,158 @@
+// Package history keeps a local JSON-lines log of evaluated metric values, so
+// past results can be inspected and unchanged values need not be resubmitted.
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Record is one evaluation of a metric for a component.
+type Record struct {
+	Component      string    `json:"component"`
+	Metric         string    `json:"metric"`
+	Value          string    `json:"value"`
+	Level          string    `json:"level,omitempty"` // level of the value, for metrics with thresholds
+	Timestamp      time.Time `json:"timestamp"`
+	Commit         string    `json:"commit,omitempty"`
+	DefinitionHash string    `json:"definitionHash,omitempty"`
+	Submitted      bool      `json:"submitted"` // false when the value was not sent to Compass
+}
+
+type key struct {
+	component string
+	metric    string
+}
+
+// Store is a history file. Records are appended; the file is read once when
+// the store is opened.
+type Store struct {
+	path      string
+	mu        sync.Mutex
+	records   []Record
+	submitted map[key][]int // indices of the records sent to Compass
+}
+
+// Open reads the history at path, which need not exist yet.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path, submitted: make(map[key][]int)}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history %s:%d: %w", path, line, err)
+		}
+		store.add(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return store, nil
+}
+
+func (s *Store) add(record Record) {
+	s.records = append(s.records, record)
+	if record.Submitted {
+		k := key{record.Component, record.Metric}
+		s.submitted[k] = append(s.submitted[k], len(s.records)-1)
+	}
+}
+
+// Append adds a record to the file.
+func (s *Store) Append(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	s.add(record)
+	return nil
+}
+
+// LastSubmitted returns the record of the value sent to Compass for the
+// component and metric that was current at the given time: the one with the
+// latest timestamp not after it. Backfilled values therefore only compare with
+// values of their own past, and live ones not with backfilled ones.
+func (s *Store) LastSubmitted(component, metric string, at time.Time) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := -1
+	for _, i := range s.submitted[key{component, metric}] {
+		timestamp := s.records[i].Timestamp
+		if timestamp.After(at) {
+			continue
+		}
+		if found < 0 || !timestamp.Before(s.records[found].Timestamp) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Record{}, false
+	}
+	return s.records[found], true
+}
+
+// Records returns the records of a component, oldest first, restricted to
+// metric when it is not empty.
+func (s *Store) Records(component, metric string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	for _, record := range s.records {
+		if record.Component == component && (metric == "" || record.Metric == metric) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// DefinitionHash identifies the content of a metric definition, so values
+// can be told apart when the rules producing them change.
+func DefinitionHash(definition services.MetricDefinition) string {
+	data, _ := json.Marshal(struct {
+		Metadata services.MetricMetadata
+		Spec     services.MetricSpec
+	}{definition.Metadata, definition.Spec})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
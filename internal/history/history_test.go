@@ -0,0 +1,58 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLastSubmittedAt(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Component: "svc", Metric: "coverage", Value: "90", Timestamp: june, Submitted: true},
+		// Backfilled after the live value
+		{Component: "svc", Metric: "coverage", Value: "70", Timestamp: may, Submitted: true},
+		// Evaluated but skipped
+		{Component: "svc", Metric: "coverage", Value: "95", Timestamp: june.Add(time.Hour)},
+	}
+	for _, record := range records {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		value string
+		found bool
+	}{
+		{name: "live run", at: june.AddDate(0, 1, 0), value: "90", found: true},
+		{name: "between the values", at: may.AddDate(0, 0, 10), value: "70", found: true},
+		{name: "same time", at: may, value: "70", found: true},
+		{name: "before any value", at: may.AddDate(0, 0, -1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := store.LastSubmitted("svc", "coverage", tt.at)
+			if ok != tt.found || record.Value != tt.value {
+				t.Errorf("LastSubmitted(%s) = %q, %v; want %q, %v", tt.at.Format(time.DateOnly), record.Value, ok, tt.value, tt.found)
+			}
+		})
+	}
+
+	// Records are read back from the file the same way
+	reopened, err := Open(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := reopened.LastSubmitted("svc", "coverage", june); record.Value != "90" {
+		t.Errorf("reopened LastSubmitted = %q, want 90", record.Value)
+	}
+}
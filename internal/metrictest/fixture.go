@@ -0,0 +1,156 @@
+// Package metrictest runs metric definitions against golden fixtures: a fake
+// repository tree, canned API and Prometheus responses and the value the
+// metric is expected to produce.
+package metrictest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/fakes"
+	"github.com/motain/compass-compute/internal/services"
+	"gopkg.in/yaml.v3"
+)
+
+// FixtureKind is the kind of fixture documents.
+const FixtureKind = "MetricTest"
+
+// FixtureSuffix marks the files fixtures are read from.
+const FixtureSuffix = ".test.yaml"
+
+// DefaultComponent is the component name fixtures are evaluated for unless
+// they set one.
+const DefaultComponent = "test-component"
+
+// Fixture is one test case of a metric definition.
+type Fixture struct {
+	Kind          string `yaml:"kind"`
+	Name          string `yaml:"name"`
+	Metric        string `yaml:"metric"`
+	ComponentType string `yaml:"componentType,omitempty"`
+	Component     string `yaml:"component,omitempty"`
+
+	// Repos maps a repository name, as facts refer to it, to its files
+	Repos map[string]map[string]string `yaml:"repos,omitempty"`
+	// API maps a URL to the response of api facts fetching it
+	API map[string]APIResponse `yaml:"api,omitempty"`
+	// Prometheus holds query results keyed by the query after placeholders
+	// are replaced
+	Prometheus struct {
+		Instant map[string]float64   `yaml:"instant,omitempty"`
+		Range   map[string][]float64 `yaml:"range,omitempty"`
+	} `yaml:"prometheus,omitempty"`
+
+	// Expect is the value the metric must evaluate to, compared as submitted
+	Expect interface{} `yaml:"expect,omitempty"`
+	// ExpectLevel is the level the value must fall in, for metrics with
+	// thresholds; with ExpectLevel set Expect may be left out
+	ExpectLevel string `yaml:"expectLevel,omitempty"`
+	// ExpectError, when set, must be contained in the evaluation error
+	ExpectError string `yaml:"expectError,omitempty"`
+
+	// Pos is the file the fixture was read from
+	Pos services.Position `yaml:"-"`
+}
+
+// APIResponse is a canned API response. A plain string is taken as the body.
+type APIResponse struct {
+	Status int         `yaml:"status,omitempty"`
+	Body   string      `yaml:"body,omitempty"`
+	JSON   interface{} `yaml:"json,omitempty"`
+}
+
+func (r *APIResponse) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.Body)
+	}
+	type plain APIResponse
+	return node.Decode((*plain)(r))
+}
+
+func (r APIResponse) response() (fakes.Response, error) {
+	response := fakes.Response{Status: r.Status, Body: r.Body}
+	if r.JSON != nil {
+		data, err := json.Marshal(r.JSON)
+		if err != nil {
+			return response, err
+		}
+		response.Body = string(data)
+	}
+	return response, nil
+}
+
+// LoadFixtures reads every fixture below dir. File positions are relative to
+// dir. Problems of all files are returned together with the fixtures that
+// could be read.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	var fixtures []Fixture
+	var problems []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(strings.ToLower(path), FixtureSuffix) {
+			return err
+		}
+
+		name := path
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			name = rel
+		}
+		fileFixtures, err := loadFile(path, name)
+		fixtures = append(fixtures, fileFixtures...)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	return fixtures, errors.Join(problems...)
+}
+
+func loadFile(path, name string) ([]Fixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Fixtures are only read by this harness, so unknown fields are typos
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	var fixtures []Fixture
+	for i := 1; ; i++ {
+		var fixture Fixture
+		if err := decoder.Decode(&fixture); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fixtures, nil
+			}
+			return fixtures, fmt.Errorf("%s: %w", name, err)
+		}
+
+		fixture.Pos = services.Position{File: name}
+		if fixture.Name == "" {
+			fixture.Name = fmt.Sprintf("case %d", i)
+		}
+		if fixture.Kind != FixtureKind {
+			return fixtures, fmt.Errorf("%s: %s: kind must be %s", name, fixture.Name, FixtureKind)
+		}
+		if fixture.Metric == "" {
+			return fixtures, fmt.Errorf("%s: %s: metric is required", name, fixture.Name)
+		}
+		if fixture.Expect == nil && fixture.ExpectLevel == "" && fixture.ExpectError == "" {
+			return fixtures, fmt.Errorf("%s: %s: one of expect, expectLevel or expectError is required", name, fixture.Name)
+		}
+		if fixture.Component == "" {
+			fixture.Component = DefaultComponent
+		}
+		fixtures = append(fixtures, fixture)
+	}
+}
@@ -0,0 +1,197 @@
+package metrictest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motain/compass-compute/internal/facts"
+	"github.com/motain/compass-compute/internal/fakes"
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/prometheus/common/model"
+)
+
+// rangeStep is the spacing of canned range query samples.
+const rangeStep = 15 * time.Second
+
+// Result is the outcome of one fixture.
+type Result struct {
+	Fixture Fixture
+	Got     string // value as it would be submitted
+	Level   string // level of Got, for metrics with thresholds
+	Err     error  // evaluation error
+	Failure string // why the fixture failed, empty when it passed
+}
+
+func (r Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// Run evaluates every fixture against the metric definitions of registry.
+func Run(registry *services.MetricRegistry, fixtures []Fixture) []Result {
+	results := make([]Result, len(fixtures))
+	for i, fixture := range fixtures {
+		results[i] = runFixture(registry, fixture)
+	}
+	return results
+}
+
+func runFixture(registry *services.MetricRegistry, fixture Fixture) Result {
+	result := Result{Fixture: fixture}
+
+	definition, err := lookup(registry, fixture)
+	if err != nil {
+		result.Failure = err.Error()
+		return result
+	}
+	componentType := fixture.ComponentType
+	if componentType == "" {
+		componentType = definition.Metadata.ComponentType[0]
+	}
+	factList, err := registry.Facts(definition.Metadata.Name, componentType)
+	if err != nil {
+		result.Failure = err.Error()
+		return result
+	}
+
+	opts, cleanup, err := fixture.environment()
+	if err != nil {
+		result.Failure = err.Error()
+		return result
+	}
+	defer cleanup()
+
+	value, err := facts.EvaluateMetric(factList, fixture.Component, opts)
+	if err == nil {
+		var converted float64
+		converted, result.Got, err = facts.ConvertValue(value, definition.Spec.Format)
+		if err == nil && definition.Spec.Thresholds != nil {
+			result.Level, _ = definition.Spec.Thresholds.Level(converted)
+		}
+	}
+	result.Err = err
+
+	// The expectation is read with the same unit so that 80, "80" and "80%"
+	// all match a percentage of 80
+	expect := fmt.Sprintf("%v", fixture.Expect)
+	if _, converted, err := facts.ConvertValue(fixture.Expect, definition.Spec.Format); err == nil {
+		expect = converted
+	}
+
+	switch {
+	case fixture.ExpectError != "" && err == nil:
+		result.Failure = fmt.Sprintf("expected error containing %q, got value %s", fixture.ExpectError, result.Got)
+	case fixture.ExpectError != "" && !strings.Contains(err.Error(), fixture.ExpectError):
+		result.Failure = fmt.Sprintf("expected error containing %q, got: %v", fixture.ExpectError, err)
+	case fixture.ExpectError == "" && err != nil:
+		result.Failure = fmt.Sprintf("evaluation failed: %v", err)
+	case fixture.ExpectError == "" && fixture.Expect != nil && result.Got != expect:
+		result.Failure = fmt.Sprintf("expected %v, got %s", fixture.Expect, result.Got)
+	case fixture.ExpectError == "" && fixture.ExpectLevel != "" && definition.Spec.Thresholds == nil:
+		result.Failure = fmt.Sprintf("expected level %s, but metric '%s' has no thresholds", fixture.ExpectLevel, fixture.Metric)
+	case fixture.ExpectError == "" && fixture.ExpectLevel != "" && result.Level != fixture.ExpectLevel:
+		result.Failure = fmt.Sprintf("expected level %s, got %s (value %s)", fixture.ExpectLevel, result.Level, result.Got)
+	}
+	return result
+}
+
+// lookup finds the definition a fixture exercises. Without a componentType the
+// metric must have a single definition.
+func lookup(registry *services.MetricRegistry, fixture Fixture) (*services.MetricDefinition, error) {
+	if fixture.ComponentType != "" {
+		definition, ok := registry.Lookup(fixture.Metric, fixture.ComponentType)
+		if !ok {
+			return nil, fmt.Errorf("no definition of metric '%s' for type '%s'", fixture.Metric, fixture.ComponentType)
+		}
+		return definition, nil
+	}
+
+	var found *services.MetricDefinition
+	for _, definition := range registry.Definitions() {
+		if definition.Metadata.Name != fixture.Metric {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("metric '%s' has several definitions, set componentType", fixture.Metric)
+		}
+		definition := definition
+		found = &definition
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no definition of metric '%s'", fixture.Metric)
+	}
+	if len(found.Metadata.ComponentType) == 0 {
+		return nil, fmt.Errorf("metric '%s' has no componentType", fixture.Metric)
+	}
+	return found, nil
+}
+
+// environment writes the fixture repositories to a temporary directory and
+// returns evaluation options backed by the canned responses.
+func (f Fixture) environment() (facts.Options, func(), error) {
+	dir, err := os.MkdirTemp("", "compass-compute-test-")
+	if err != nil {
+		return facts.Options{}, nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	for repo, files := range f.Repos {
+		if err := fakes.WriteTree(filepath.Join(dir, repo), files); err != nil {
+			cleanup()
+			return facts.Options{}, nil, err
+		}
+	}
+
+	transport := fakes.NewTransport()
+	for url, response := range f.API {
+		canned, err := response.response()
+		if err != nil {
+			cleanup()
+			return facts.Options{}, nil, fmt.Errorf("invalid api response for %s: %w", url, err)
+		}
+		transport.Responses[url] = canned
+	}
+
+	prometheus := fakes.NewPrometheus()
+	for query, value := range f.Prometheus.Instant {
+		prometheus.Instant[query] = value
+	}
+	for query, values := range f.Prometheus.Range {
+		stream := &model.SampleStream{Metric: model.Metric{}}
+		for i, value := range values {
+			stream.Values = append(stream.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(int64(i) * rangeStep.Nanoseconds()),
+				Value:     model.SampleValue(value),
+			})
+		}
+		prometheus.Range[query] = model.Matrix{stream}
+	}
+
+	return facts.Options{
+		BasePath:   dir,
+		Prometheus: prometheus,
+		HTTPClient: transport.Client(),
+	}, cleanup, nil
+}
+
+// PrintResults writes one line per fixture, with the failure reason of failed
+// ones, and returns the number of failures.
+func PrintResults(w io.Writer, results []Result, verbose bool) int {
+	failed := 0
+	for _, result := range results {
+		fixture := result.Fixture
+		if result.Passed() {
+			if verbose {
+				fmt.Fprintf(w, "PASS  %s: %s (%s)\n", fixture.Metric, fixture.Name, fixture.Pos)
+			}
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s: %s (%s)\n      %s\n", fixture.Metric, fixture.Name, fixture.Pos, result.Failure)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,204 @@
+package metrictest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+const coverageMetric = `apiVersion: v2
+kind: Metric
+metadata:
+  name: test-coverage
+  componentType: ["service"]
+spec:
+  format:
+    unit: "%"
+  thresholds:
+    levels:
+      - {name: gold, operator: ">=", value: 90}
+      - {name: silver, operator: ">=", value: 80}
+  facts:
+    - id: coverage
+      type: extract
+      source: github
+      repo: ${Metadata.Name}
+      filePath: coverage.json
+      rule: jsonpath
+      jsonPath: ".total.pct"
+---
+apiVersion: v2
+kind: Metric
+metadata:
+  name: open-incidents
+  componentType: ["service"]
+spec:
+  format:
+    unit: count
+  facts:
+    - id: incidents
+      type: extract
+      source: api
+      uri: https://incidents.example.com/${Metadata.Name}
+      rule: jsonpath
+      jsonPath: ".open | length"
+`
+
+const coverageFixtures = `kind: MetricTest
+name: passes
+metric: test-coverage
+component: svc
+repos:
+  svc:
+    coverage.json: '{"total": {"pct": 85.123}}'
+expect: "85.12%"
+expectLevel: silver
+---
+kind: MetricTest
+name: wrong value
+metric: test-coverage
+component: svc
+repos:
+  svc:
+    coverage.json: '{"total": {"pct": 85.123}}'
+expect: 90
+---
+kind: MetricTest
+name: wrong level
+metric: test-coverage
+component: svc
+repos:
+  svc:
+    coverage.json: '{"total": {"pct": 95}}'
+expectLevel: silver
+---
+kind: MetricTest
+name: api down
+metric: open-incidents
+component: svc
+expectError: no canned response
+---
+kind: MetricTest
+name: unexpected error
+metric: open-incidents
+component: svc
+expect: 0
+---
+kind: MetricTest
+name: incidents
+metric: open-incidents
+component: svc
+api:
+  https://incidents.example.com/svc:
+    json: {open: [1, 2]}
+expect: 2
+`
+
+// metricDir writes a metric directory with the given files.
+func metricDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := metricDir(t, map[string]string{
+		"coverage.yaml":      coverageMetric,
+		"coverage.test.yaml": coverageFixtures,
+	})
+	registry, err := services.LoadMetricRegistry(dir, services.MetricParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		failure string // contained in the failure, empty for passing fixtures
+		got     string
+		level   string
+	}{
+		"passes":           {got: "85.12", level: "silver"},
+		"wrong value":      {failure: "expected 90, got 85.12", got: "85.12", level: "silver"},
+		"wrong level":      {failure: "expected level silver, got gold", got: "95", level: "gold"},
+		"api down":         {},
+		"unexpected error": {failure: "evaluation failed"},
+		"incidents":        {got: "2"},
+	}
+	results := Run(registry, fixtures)
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for _, result := range results {
+		name := result.Fixture.Name
+		expected, ok := want[name]
+		if !ok {
+			t.Errorf("unexpected fixture %q", name)
+			continue
+		}
+		if result.Passed() != (expected.failure == "") || !strings.Contains(result.Failure, expected.failure) {
+			t.Errorf("%s: failure = %q, want %q", name, result.Failure, expected.failure)
+		}
+		if result.Got != expected.got || result.Level != expected.level {
+			t.Errorf("%s: got %q at level %q, want %q at level %q", name, result.Got, result.Level, expected.got, expected.level)
+		}
+	}
+
+	var out bytes.Buffer
+	if failed := PrintResults(&out, results, false); failed != 3 {
+		t.Errorf("PrintResults() = %d failures, want 3", failed)
+	}
+	if report := out.String(); !strings.Contains(report, "FAIL  test-coverage: wrong value (coverage.test.yaml)") ||
+		strings.Contains(report, "PASS") || !strings.HasSuffix(report, "3 passed, 3 failed\n") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestRunUnknownMetric(t *testing.T) {
+	dir := metricDir(t, map[string]string{
+		"coverage.yaml": coverageMetric,
+		"other.test.yaml": `kind: MetricTest
+metric: deploy-frequency
+expect: 1
+`,
+	})
+	registry, err := services.LoadMetricRegistry(dir, services.MetricParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := Run(registry, fixtures)
+	if len(results) != 1 || results[0].Passed() {
+		t.Fatalf("fixture of an unknown metric passed: %+v", results)
+	}
+}
+
+func TestLoadFixturesRequiresExpectation(t *testing.T) {
+	dir := metricDir(t, map[string]string{"empty.test.yaml": "kind: MetricTest\nname: nothing\nmetric: test-coverage\n"})
+	_, err := LoadFixtures(dir)
+	if err == nil || !strings.Contains(err.Error(), "nothing: one of expect, expectLevel or expectError is required") {
+		t.Errorf("LoadFixtures() error = %v, want one asking for an expectation", err)
+	}
+}
+
+func TestLoadFixturesRejectsUnknownFields(t *testing.T) {
+	dir := metricDir(t, map[string]string{"typo.test.yaml": "kind: MetricTest\nmetric: test-coverage\nexpected: 1\n"})
+	if _, err := LoadFixtures(dir); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("LoadFixtures() error = %v, want one naming the unknown field", err)
+	}
+}
@@ -0,0 +1,114 @@
+package recording
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+type fetcher struct {
+	store *Store
+	base  services.RepoFetcher
+}
+
+// Fetcher records or replays the checkouts made by base: the resolved commit
+// and every file written, which are the files facts read. base is not used
+// when replaying and may be nil.
+func (s *Store) Fetcher(base services.RepoFetcher) services.RepoFetcher {
+	return &fetcher{store: s, base: base}
+}
+
+func (f *fetcher) Clone(info *services.GitInfo, repoPath string, paths []string) (string, error) {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	key := fmt.Sprintf("%s/%s/%s@%s path=%s sparse=%s",
+		info.Host, info.Owner, info.Repo, info.Branch, info.Path, strings.Join(sorted, ","))
+	if !info.Before.IsZero() {
+		key += " before=" + info.Before.UTC().Format(time.RFC3339)
+	}
+
+	if f.store.replay {
+		e, err := f.store.load(KindRepo, key)
+		if err != nil {
+			return "", err
+		}
+		if err := e.replayError(); err != nil {
+			return "", err
+		}
+		if err := restoreTree(repoPath, e.Files); err != nil {
+			return "", err
+		}
+		return e.Commit, nil
+	}
+
+	commit, err := f.base.Clone(info, repoPath, paths)
+	e := entry{Kind: KindRepo, Key: key, Commit: commit, Error: errorString(err)}
+	if err == nil {
+		if e.Files, err = snapshotTree(repoPath); err != nil {
+			return "", err
+		}
+	}
+	if saveErr := f.store.save(e); saveErr != nil {
+		fmt.Printf("Warning: %v\n", saveErr)
+	}
+	return commit, err
+}
+
+// snapshotTree reads the regular files below dir, skipping git metadata.
+func snapshotTree(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record checkout %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// restoreTree replaces dir with the recorded files.
+func restoreTree(dir string, files map[string][]byte) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clean up existing directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	for name, data := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to restore file %s: %w", name, err)
+		}
+	}
+	return nil
+}
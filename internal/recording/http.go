@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type transport struct {
+	store *Store
+	base  http.RoundTripper
+}
+
+// Transport records or replays the requests sent through base. Requests are
+// keyed by method, URL and body, so GraphQL operations are told apart by
+// their query and variables; headers such as credentials are not recorded.
+func (s *Store) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{store: s, base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key := req.Method + " " + req.URL.String()
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		key += " body:" + hex.EncodeToString(sum[:8])
+	}
+
+	if t.store.replay {
+		e, err := t.store.load(KindHTTP, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.replayError(); err != nil {
+			return nil, err
+		}
+		return response(req, e.Status, e.Body), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		if saveErr := t.store.save(entry{Kind: KindHTTP, Key: key, Error: err.Error()}); saveErr != nil {
+			fmt.Printf("Warning: %v\n", saveErr)
+		}
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := t.store.save(entry{Kind: KindHTTP, Key: key, Status: resp.StatusCode, Body: string(data)}); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,120 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/motain/compass-compute/internal/services"
+	"github.com/prometheus/common/model"
+)
+
+type prometheus struct {
+	store *Store
+	base  services.PrometheusServiceInterface
+}
+
+// Prometheus records or replays the queries answered by base. Queries for the
+// current time are keyed by query, and step for range queries; windows ending
+// now move with the clock, so only past evaluation times (--at) are part of
+// the key.
+func (s *Store) Prometheus(base services.PrometheusServiceInterface) services.PrometheusServiceInterface {
+	return &prometheus{store: s, base: base}
+}
+
+func (p *prometheus) InstantQuery(queryString string, at time.Time) (float64, error) {
+	key := "instant " + queryString + atKey(at)
+	if p.store.replay {
+		e, err := p.store.load(KindPrometheus, key)
+		if err != nil {
+			return 0, err
+		}
+		if err := e.replayError(); err != nil {
+			return 0, err
+		}
+		var value float64
+		if err := json.Unmarshal(e.Value, &value); err != nil {
+			return 0, fmt.Errorf("failed to decode recorded value of %s: %w", key, err)
+		}
+		return value, nil
+	}
+
+	value, err := p.base.InstantQuery(queryString, at)
+	e := entry{Kind: KindPrometheus, Key: key, Error: errorString(err)}
+	if err == nil {
+		e.Value, _ = json.Marshal(value)
+	}
+	if saveErr := p.store.save(e); saveErr != nil {
+		fmt.Printf("Warning: %v\n", saveErr)
+	}
+	return value, err
+}
+
+func (p *prometheus) RangeQuery(queryString string, start, end time.Time, step time.Duration) (model.Value, error) {
+	key := fmt.Sprintf("range %s step=%s", queryString, step)
+	if time.Since(end) > time.Minute {
+		key += atKey(end)
+	}
+	if p.store.replay {
+		e, err := p.store.load(KindPrometheus, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.replayError(); err != nil {
+			return nil, err
+		}
+		return decodeValue(e.ValueType, e.Value)
+	}
+
+	value, err := p.base.RangeQuery(queryString, start, end, step)
+	e := entry{Kind: KindPrometheus, Key: key, Error: errorString(err)}
+	if err == nil && value != nil {
+		e.ValueType = value.Type().String()
+		if e.Value, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to encode result of %s: %w", key, err)
+		}
+	}
+	if saveErr := p.store.save(e); saveErr != nil {
+		fmt.Printf("Warning: %v\n", saveErr)
+	}
+	return value, err
+}
+
+// atKey is the key suffix of a query evaluated at a fixed time.
+func atKey(at time.Time) string {
+	if at.IsZero() {
+		return ""
+	}
+	return " at=" + at.UTC().Format(time.RFC3339)
+}
+
+// decodeValue restores a query result of the given model.ValueType.
+func decodeValue(valueType string, data json.RawMessage) (model.Value, error) {
+	var value model.Value
+	switch valueType {
+	case "":
+		return nil, nil
+	case model.ValMatrix.String():
+		value = &model.Matrix{}
+	case model.ValVector.String():
+		value = &model.Vector{}
+	case model.ValScalar.String():
+		value = &model.Scalar{}
+	case model.ValString.String():
+		value = &model.String{}
+	default:
+		return nil, fmt.Errorf("unsupported recorded value type '%s'", valueType)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("failed to decode recorded %s: %w", valueType, err)
+	}
+
+	// Callers expect values, as returned by the client
+	switch v := value.(type) {
+	case *model.Matrix:
+		return *v, nil
+	case *model.Vector:
+		return *v, nil
+	}
+	return value, nil
+}
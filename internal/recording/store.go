@@ -0,0 +1,130 @@
+// Package recording captures the responses of every external source a run
+// reads - HTTP APIs including Compass GraphQL, Prometheus and repository
+// checkouts - and serves them back, so a run can be reproduced offline.
+package recording
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Entry kinds.
+const (
+	KindHTTP       = "http"
+	KindPrometheus = "prometheus"
+	KindRepo       = "repo"
+)
+
+// entry is one recorded interaction, stored as <kind>-<hash of key>.json.
+type entry struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+
+	// Error is the error the source returned; replayed as an error
+	Error string `json:"error,omitempty"`
+
+	// HTTP responses
+	Status int    `json:"status,omitempty"`
+	Body   string `json:"body,omitempty"`
+
+	// Prometheus results
+	Value     json.RawMessage `json:"value,omitempty"`
+	ValueType string          `json:"valueType,omitempty"`
+
+	// Repository checkouts, files keyed by slash separated path
+	Commit string            `json:"commit,omitempty"`
+	Files  map[string][]byte `json:"files,omitempty"`
+}
+
+// Store records interactions to, or replays them from, a directory.
+type Store struct {
+	dir    string
+	replay bool
+	mu     sync.Mutex
+}
+
+// NewRecorder returns a store writing interactions to dir, creating it if needed.
+func NewRecorder(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// NewReplayer returns a store serving the interactions recorded in dir.
+func NewReplayer(dir string) (*Store, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("recording %s is not a directory", dir)
+	}
+	return &Store{dir: dir, replay: true}, nil
+}
+
+// Replaying reports whether the store serves recorded interactions.
+func (s *Store) Replaying() bool {
+	return s.replay
+}
+
+func (s *Store) path(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, kind+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// save writes e, keeping the first recording of a key.
+func (s *Store) save(e entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(e.Kind, e.Key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// load reads the recording of key.
+func (s *Store) load(kind, key string) (entry, error) {
+	var e entry
+	data, err := os.ReadFile(s.path(kind, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return e, fmt.Errorf("no recording for %s %s", kind, key)
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to read recording: %w", err)
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, fmt.Errorf("failed to decode recording of %s %s: %w", kind, key, err)
+	}
+	return e, nil
+}
+
+// replayError turns a recorded source error back into an error.
+func (e entry) replayError() error {
+	if e.Error == "" {
+		return nil
+	}
+	return errors.New(e.Error)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,158 @@
+package scorecard
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Pass/fail grades of scorecards without grades.
+const (
+	GradePassed = "passed"
+	GradeFailed = "failed"
+)
+
+// CriterionResult is the outcome of one criterion for a component.
+type CriterionResult struct {
+	Criterion Criterion
+	Value     *float64 // nil when the component has no value for the metric
+	Passed    bool
+	// Skipped is set when the metric was not evaluated, e.g. because of a
+	// metric filter; skipped criteria do not count towards the score
+	Skipped bool
+}
+
+// Result is the grade of a component on a scorecard.
+type Result struct {
+	Scorecard string
+	Component string
+	Score     float64 // share of the criteria weight met, 0 to 100
+	Grade     string
+	Criteria  []CriterionResult
+}
+
+// Failed returns the criteria the component did not meet.
+func (r Result) Failed() []CriterionResult {
+	var failed []CriterionResult
+	for _, criterion := range r.Criteria {
+		if !criterion.Passed && !criterion.Skipped {
+			failed = append(failed, criterion)
+		}
+	}
+	return failed
+}
+
+// Skipped returns the criteria over metrics that were not evaluated.
+func (r Result) Skipped() []CriterionResult {
+	var skipped []CriterionResult
+	for _, criterion := range r.Criteria {
+		if criterion.Skipped {
+			skipped = append(skipped, criterion)
+		}
+	}
+	return skipped
+}
+
+// Grade scores a component on the scorecard from its metric values, keyed by
+// metric name. Criteria over metrics without a value are not met; criteria
+// over the metrics in skipped were not evaluated and are left out of the
+// score. A component with every criterion skipped gets no grade.
+func (s Scorecard) Grade(component string, values map[string]float64, skipped map[string]bool) Result {
+	result := Result{Scorecard: s.Metadata.Name, Component: component}
+
+	total, met := 0, 0
+	for _, criterion := range s.Spec.Criteria {
+		outcome := CriterionResult{Criterion: criterion}
+		if skipped[criterion.Metric] {
+			outcome.Skipped = true
+			result.Criteria = append(result.Criteria, outcome)
+			continue
+		}
+		if value, ok := values[criterion.Metric]; ok {
+			outcome.Value = &value
+			outcome.Passed = criterion.Matches(value)
+		}
+		total += criterion.Weight
+		if outcome.Passed {
+			met += criterion.Weight
+		}
+		result.Criteria = append(result.Criteria, outcome)
+	}
+	if total > 0 {
+		result.Score = float64(met) * 100 / float64(total)
+	}
+
+	switch {
+	case total == 0:
+		// Nothing was evaluated
+	case len(s.Spec.Grades) == 0 && met == total:
+		result.Grade = GradePassed
+	case len(s.Spec.Grades) == 0:
+		result.Grade = GradeFailed
+	default:
+		// Grades are sorted from the highest score down
+		for _, grade := range s.Spec.Grades {
+			if result.Score >= grade.MinScore {
+				result.Grade = grade.Name
+				break
+			}
+		}
+	}
+	return result
+}
+
+// PrintResults writes a table of grades. With verbose set the criteria each
+// component missed are listed below it.
+func PrintResults(w io.Writer, results []Result, verbose bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tSCORECARD\tSCORE\tGRADE\tFAILED CRITERIA")
+	for _, result := range results {
+		grade := result.Grade
+		if grade == "" {
+			grade = "-"
+		}
+		failed, skipped := result.Failed(), result.Skipped()
+		criteria := fmt.Sprintf("%d of %d", len(failed), len(result.Criteria)-len(skipped))
+		if len(skipped) > 0 {
+			criteria += fmt.Sprintf(" (%d not evaluated)", len(skipped))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.Component, result.Scorecard,
+			strconv.FormatFloat(result.Score, 'f', 1, 64), grade, criteria)
+		if !verbose {
+			continue
+		}
+		for _, criterion := range failed {
+			value := "no value"
+			if criterion.Value != nil {
+				value = "value " + strconv.FormatFloat(*criterion.Value, 'f', -1, 64)
+			}
+			fmt.Fprintf(tw, "\t  - %s (%s, weight %d)\t\t\t\n", criterion.Criterion.Title(), value, criterion.Criterion.Weight)
+		}
+		for _, criterion := range skipped {
+			fmt.Fprintf(tw, "\t  ? %s (not evaluated)\t\t\t\n", criterion.Criterion.Title())
+		}
+	}
+	return tw.Flush()
+}
+
+// Summary describes a result in one line.
+func (r Result) Summary() string {
+	var missed []string
+	for _, criterion := range r.Failed() {
+		missed = append(missed, criterion.Criterion.Title())
+	}
+	grade := r.Grade
+	if grade == "" {
+		grade = "-"
+	}
+	summary := fmt.Sprintf("scored %s on '%s', grade %s", strconv.FormatFloat(r.Score, 'f', 1, 64), r.Scorecard, grade)
+	if len(missed) > 0 {
+		summary += "; missed: " + strings.Join(missed, ", ")
+	}
+	if skipped := r.Skipped(); len(skipped) > 0 {
+		summary += fmt.Sprintf("; %d criteria not evaluated", len(skipped))
+	}
+	return summary
+}
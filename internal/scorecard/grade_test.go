@@ -0,0 +1,69 @@
+package scorecard
+
+import (
+	"testing"
+
+	"github.com/motain/compass-compute/internal/services"
+)
+
+func TestGrade(t *testing.T) {
+	card := Scorecard{
+		Metadata: Metadata{Name: "ready"},
+		Spec: Spec{
+			Criteria: []Criterion{
+				{Metric: "coverage", Weight: 60, Condition: services.Condition{Operator: ">=", Value: 80}},
+				{Metric: "readme", Weight: 40, Condition: services.Condition{Operator: "==", Value: 1}},
+			},
+			// Sorted from the highest score down, as Validate leaves them
+			Grades: []Grade{{Name: "Gold", MinScore: 100}, {Name: "Silver", MinScore: 60}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]float64
+		skipped map[string]bool
+		score   float64
+		grade   string
+		failed  int
+	}{
+		{name: "all met", values: map[string]float64{"coverage": 85, "readme": 1}, score: 100, grade: "Gold"},
+		{name: "one missed", values: map[string]float64{"coverage": 85, "readme": 0}, score: 60, grade: "Silver", failed: 1},
+		{name: "no value", values: map[string]float64{"readme": 1}, score: 40, failed: 1},
+		{
+			name:    "filtered metric is not a miss",
+			values:  map[string]float64{"readme": 1},
+			skipped: map[string]bool{"coverage": true},
+			score:   100,
+			grade:   "Gold",
+		},
+		{
+			name:    "nothing evaluated",
+			skipped: map[string]bool{"coverage": true, "readme": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := card.Grade("svc", tt.values, tt.skipped)
+			if result.Score != tt.score || result.Grade != tt.grade || len(result.Failed()) != tt.failed {
+				t.Errorf("Grade() = score %v, grade %q, %d failed; want %v, %q, %d",
+					result.Score, result.Grade, len(result.Failed()), tt.score, tt.grade, tt.failed)
+			}
+			if len(result.Skipped()) != len(tt.skipped) {
+				t.Errorf("Skipped() = %d criteria, want %d", len(result.Skipped()), len(tt.skipped))
+			}
+		})
+	}
+}
+
+func TestGradeWithoutGrades(t *testing.T) {
+	card := Scorecard{Spec: Spec{Criteria: []Criterion{
+		{Metric: "coverage", Weight: 100, Condition: services.Condition{Operator: ">", Value: 80}},
+	}}}
+	if grade := card.Grade("svc", map[string]float64{"coverage": 81}, nil).Grade; grade != GradePassed {
+		t.Errorf("grade above threshold = %q, want %q", grade, GradePassed)
+	}
+	if grade := card.Grade("svc", map[string]float64{"coverage": 80}, nil).Grade; grade != GradeFailed {
+		t.Errorf("grade at threshold of > = %q, want %q", grade, GradeFailed)
+	}
+}
@@ -0,0 +1,233 @@
+// Package scorecard grades components on weighted criteria over their metric
+// values. Scorecards live next to the metric definitions as documents of kind
+// Scorecard and can be synced to Compass.
+package scorecard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/services"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the kind of scorecard documents.
+const Kind = "Scorecard"
+
+// Importance levels, as Compass knows them.
+const (
+	ImportanceRequired    = "required"
+	ImportanceRecommended = "recommended"
+	ImportanceUserDefined = "user-defined"
+)
+
+// Scorecard is a scorecard definition.
+type Scorecard struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   Metadata `yaml:"metadata"`
+	Spec       Spec     `yaml:"spec"`
+
+	// Pos is where the definition document starts
+	Pos services.Position `yaml:"-"`
+}
+
+type Metadata struct {
+	Name          string   `yaml:"name"`
+	ComponentType []string `yaml:"componentType"`
+}
+
+type Spec struct {
+	Description string `yaml:"description,omitempty"`
+	// Importance is required, recommended (default) or user-defined
+	Importance string `yaml:"importance,omitempty"`
+	// Owner is the ID of the team owning the scorecard in Compass
+	Owner    string      `yaml:"owner,omitempty"`
+	Criteria []Criterion `yaml:"criteria"`
+	// Grades map scores to names; without grades a component passes when it
+	// meets every criterion
+	Grades []Grade `yaml:"grades,omitempty"`
+}
+
+// Criterion is met when the value of Metric satisfies the condition.
+type Criterion struct {
+	Name               string `yaml:"name,omitempty"`
+	Metric             string `yaml:"metric"`
+	Weight             int    `yaml:"weight"`
+	services.Condition `yaml:",inline"`
+}
+
+// Title is the name of the criterion, or its condition when it has none.
+func (c Criterion) Title() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Metric + " " + c.Condition.String()
+}
+
+// Grade is awarded to components scoring at least MinScore.
+type Grade struct {
+	Name     string  `yaml:"name"`
+	MinScore float64 `yaml:"minScore"`
+}
+
+// AppliesTo reports whether components of componentType are graded.
+func (s Scorecard) AppliesTo(componentType string) bool {
+	for _, t := range s.Metadata.ComponentType {
+		if strings.EqualFold(t, componentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the scorecards below dir, skipping documents of other kinds.
+// Positions are relative to dir. Unknown fields are errors.
+func Load(dir string) ([]Scorecard, error) {
+	var scorecards []Scorecard
+	var problems []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		lower := strings.ToLower(path)
+		if !strings.HasSuffix(lower, ".yaml") && !strings.HasSuffix(lower, ".yml") {
+			return nil
+		}
+
+		name := path
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			name = rel
+		}
+		fileScorecards, err := loadFile(path, name)
+		scorecards = append(scorecards, fileScorecards...)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scorecards: %w", err)
+	}
+	return scorecards, errors.Join(problems...)
+}
+
+func loadFile(path, name string) ([]Scorecard, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var scorecards []Scorecard
+	decoder := yaml.NewDecoder(file)
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			// Syntax errors end the file; the metric parser reports them
+			return scorecards, nil
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		pos := services.Position{File: name, Line: doc.Content[0].Line}
+
+		var header struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := doc.Decode(&header); err != nil || header.Kind != Kind {
+			continue
+		}
+
+		scorecard, err := decode(&doc)
+		if err != nil {
+			return scorecards, fmt.Errorf("%s: %w", pos, err)
+		}
+		scorecard.Pos = pos
+		scorecards = append(scorecards, scorecard)
+	}
+}
+
+// decode reads a scorecard document, rejecting unknown fields.
+func decode(doc *yaml.Node) (Scorecard, error) {
+	var scorecard Scorecard
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return scorecard, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&scorecard)
+	return scorecard, err
+}
+
+// Validate checks the scorecards against each other and against the metrics
+// they grade: every criterion metric must be defined, directly or as the level
+// metric of thresholds, for every component type of its scorecard. Grades are sorted from the highest score down.
+func Validate(scorecards []Scorecard, metrics *services.MetricRegistry) error {
+	var problems []error
+	names := make(map[string]services.Position)
+	for i := range scorecards {
+		scorecard := &scorecards[i]
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Errorf("%s: scorecard '%s': %s", scorecard.Pos, scorecard.Metadata.Name, fmt.Sprintf(format, args...)))
+		}
+
+		if scorecard.Metadata.Name == "" {
+			fail("metadata.name is required")
+		} else if prev, ok := names[scorecard.Metadata.Name]; ok {
+			fail("also defined at %s", prev)
+		} else {
+			names[scorecard.Metadata.Name] = scorecard.Pos
+		}
+		if len(scorecard.Metadata.ComponentType) == 0 {
+			fail("metadata.componentType is required")
+		}
+		switch scorecard.Spec.Importance {
+		case "", ImportanceRequired, ImportanceRecommended, ImportanceUserDefined:
+		default:
+			fail("unknown importance '%s', expected required, recommended or user-defined", scorecard.Spec.Importance)
+		}
+		if len(scorecard.Spec.Criteria) == 0 {
+			fail("at least one criterion is required")
+		}
+
+		for _, criterion := range scorecard.Spec.Criteria {
+			if criterion.Metric == "" {
+				fail("criterion '%s' has no metric", criterion.Title())
+				continue
+			}
+			if criterion.Weight <= 0 {
+				fail("criterion '%s' needs a positive weight", criterion.Title())
+			}
+			if err := criterion.Condition.Validate(); err != nil {
+				fail("criterion '%s': %v", criterion.Title(), err)
+			}
+			for _, componentType := range scorecard.Metadata.ComponentType {
+				_, defined := metrics.Lookup(criterion.Metric, componentType)
+				if _, level := metrics.LevelSource(criterion.Metric, componentType); !defined && !level {
+					fail("criterion '%s': metric '%s' is not defined for type '%s'", criterion.Title(), criterion.Metric, componentType)
+				}
+			}
+		}
+
+		for _, grade := range scorecard.Spec.Grades {
+			if grade.Name == "" {
+				fail("grades need a name")
+			}
+			if grade.MinScore < 0 || grade.MinScore > 100 {
+				fail("grade '%s': minScore must be between 0 and 100", grade.Name)
+			}
+		}
+		sort.SliceStable(scorecard.Spec.Grades, func(a, b int) bool {
+			return scorecard.Spec.Grades[a].MinScore > scorecard.Spec.Grades[b].MinScore
+		})
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,255 @@
+package scorecard
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/motain/compass-compute/internal/definitions"
+	"github.com/motain/compass-compute/internal/services"
+)
+
+// Change is the planned action for one scorecard.
+type Change struct {
+	Action  definitions.Action
+	Desired services.CompassScorecard
+	Current *services.CompassScorecard
+	// Removed lists the IDs of criteria to delete on update
+	Removed []string
+	Source  services.Position
+}
+
+// Desired derives the Compass scorecards from the definitions. Criteria refer
+// to metric definitions by ID, so these must exist in Compass already, and
+// Compass requires criteria weights to add up to 100.
+func Desired(scorecards []Scorecard, metricDefinitions []services.CompassMetricDefinition) ([]services.CompassScorecard, error) {
+	ids := make(map[string]string)
+	for _, definition := range metricDefinitions {
+		ids[definition.Name] = definition.ID
+	}
+
+	var desired []services.CompassScorecard
+	var problems []string
+	for _, scorecard := range scorecards {
+		compass := services.CompassScorecard{
+			Name:        scorecard.Metadata.Name,
+			Description: strings.TrimSpace(scorecard.Spec.Description),
+			Importance:  compassEnum(scorecard.Spec.Importance, ImportanceRecommended),
+			OwnerID:     scorecard.Spec.Owner,
+		}
+		for _, componentType := range scorecard.Metadata.ComponentType {
+			compass.ComponentTypes = append(compass.ComponentTypes, strings.ToUpper(componentType))
+		}
+
+		total := 0
+		for _, criterion := range scorecard.Spec.Criteria {
+			total += criterion.Weight
+			id, ok := ids[criterion.Metric]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: metric '%s' of scorecard '%s' is not in Compass, run 'definitions sync --apply' first",
+					scorecard.Pos, criterion.Metric, compass.Name))
+				continue
+			}
+			compass.Criteria = append(compass.Criteria, services.CompassScorecardCriterion{
+				Name:               criterion.Title(),
+				Weight:             criterion.Weight,
+				MetricDefinitionID: id,
+				Comparator:         criterion.Comparator(),
+				ComparatorValue:    criterion.Value,
+			})
+		}
+		if total != 100 {
+			problems = append(problems, fmt.Sprintf("%s: criteria weights of scorecard '%s' add up to %d; Compass requires 100",
+				scorecard.Pos, compass.Name, total))
+		}
+		desired = append(desired, compass)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("scorecards cannot be synced:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return desired, nil
+}
+
+// compassEnum turns a YAML value such as user-defined into USER_DEFINED.
+func compassEnum(value, fallback string) string {
+	if value == "" {
+		value = fallback
+	}
+	return strings.ToUpper(strings.ReplaceAll(value, "-", "_"))
+}
+
+// Plan compares the scorecard definitions with the scorecards in Compass,
+// matched by name. Criteria are matched by name so that unchanged ones keep
+// their IDs.
+func Plan(scorecards []Scorecard, metricDefinitions []services.CompassMetricDefinition, current []services.CompassScorecard) ([]Change, error) {
+	desired, err := Desired(scorecards, metricDefinitions)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*services.CompassScorecard)
+	for i := range current {
+		byName[current[i].Name] = &current[i]
+	}
+
+	var changes []Change
+	for i, scorecard := range desired {
+		change := Change{Desired: scorecard, Source: scorecards[i].Pos}
+		existing, ok := byName[scorecard.Name]
+		if !ok {
+			change.Action = definitions.ActionCreate
+			changes = append(changes, change)
+			continue
+		}
+		delete(byName, scorecard.Name)
+
+		change.Current = existing
+		change.Desired.ID = existing.ID
+		change.Desired.Criteria, change.Removed = matchCriteria(scorecard.Criteria, existing.Criteria)
+		change.Action = definitions.ActionUnchanged
+		if !equal(change.Desired, *existing) {
+			change.Action = definitions.ActionUpdate
+		}
+		changes = append(changes, change)
+	}
+
+	for _, existing := range byName {
+		changes = append(changes, Change{Action: definitions.ActionUnmanaged, Current: existing, Desired: *existing})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Desired.Name < changes[j].Desired.Name
+	})
+	return changes, nil
+}
+
+// matchCriteria gives desired criteria the IDs of the current ones with the
+// same name and returns the IDs of current criteria no longer wanted.
+func matchCriteria(desired, current []services.CompassScorecardCriterion) ([]services.CompassScorecardCriterion, []string) {
+	ids := make(map[string]string)
+	for _, criterion := range current {
+		ids[criterion.Name] = criterion.ID
+	}
+
+	matched := make([]services.CompassScorecardCriterion, len(desired))
+	for i, criterion := range desired {
+		criterion.ID = ids[criterion.Name]
+		delete(ids, criterion.Name)
+		matched[i] = criterion
+	}
+
+	var removed []string
+	for _, criterion := range current {
+		if _, ok := ids[criterion.Name]; ok {
+			removed = append(removed, criterion.ID)
+		}
+	}
+	return matched, removed
+}
+
+func equal(a, b services.CompassScorecard) bool {
+	sortedTypes := func(types []string) []string {
+		sorted := append([]string(nil), types...)
+		sort.Strings(sorted)
+		return sorted
+	}
+	byName := func(criteria []services.CompassScorecardCriterion) map[string]services.CompassScorecardCriterion {
+		m := make(map[string]services.CompassScorecardCriterion)
+		for _, criterion := range criteria {
+			m[criterion.Name] = criterion
+		}
+		return m
+	}
+	return a.Description == b.Description &&
+		a.Importance == b.Importance &&
+		(a.OwnerID == "" || a.OwnerID == b.OwnerID) &&
+		reflect.DeepEqual(sortedTypes(a.ComponentTypes), sortedTypes(b.ComponentTypes)) &&
+		len(a.Criteria) == len(b.Criteria) &&
+		reflect.DeepEqual(byName(a.Criteria), byName(b.Criteria))
+}
+
+// Pending reports whether applying changes would modify Compass.
+func Pending(changes []Change) bool {
+	for _, change := range changes {
+		if change.Action == definitions.ActionCreate || change.Action == definitions.ActionUpdate {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintPlan writes a diff-like summary of changes. Unchanged scorecards are
+// only listed when verbose is set.
+func PrintPlan(w io.Writer, changes []Change, verbose bool) {
+	counts := make(map[definitions.Action]int)
+	for _, change := range changes {
+		counts[change.Action]++
+	}
+	fmt.Fprintf(w, "Scorecards: %d to create, %d to update, %d unchanged, %d only in Compass\n",
+		counts[definitions.ActionCreate], counts[definitions.ActionUpdate], counts[definitions.ActionUnchanged], counts[definitions.ActionUnmanaged])
+
+	for _, change := range changes {
+		desired := change.Desired
+		switch change.Action {
+		case definitions.ActionCreate:
+			fmt.Fprintf(w, "  + %s (%s)\n", desired.Name, change.Source)
+			for _, criterion := range desired.Criteria {
+				fmt.Fprintf(w, "      + %s (weight %d)\n", criterion.Name, criterion.Weight)
+			}
+		case definitions.ActionUpdate:
+			fmt.Fprintf(w, "  ~ %s (%s)\n", desired.Name, change.Source)
+			current := make(map[string]services.CompassScorecardCriterion)
+			for _, criterion := range change.Current.Criteria {
+				current[criterion.ID] = criterion
+			}
+			for _, criterion := range desired.Criteria {
+				switch existing, ok := current[criterion.ID]; {
+				case criterion.ID == "" || !ok:
+					fmt.Fprintf(w, "      + %s (weight %d)\n", criterion.Name, criterion.Weight)
+				case existing != criterion:
+					fmt.Fprintf(w, "      ~ %s (weight %d)\n", criterion.Name, criterion.Weight)
+				}
+			}
+			for _, id := range change.Removed {
+				fmt.Fprintf(w, "      - %s\n", current[id].Name)
+			}
+		case definitions.ActionUnchanged:
+			if verbose {
+				fmt.Fprintf(w, "  = %s\n", desired.Name)
+			}
+		case definitions.ActionUnmanaged:
+			fmt.Fprintf(w, "  ? %s exists in Compass but not in the catalog, left untouched\n", desired.Name)
+		}
+	}
+}
+
+// Apply creates and updates scorecards in Compass. It carries on past
+// failures and returns them together.
+func Apply(compass services.CompassClient, changes []Change) error {
+	var failed []string
+	for _, change := range changes {
+		switch change.Action {
+		case definitions.ActionCreate:
+			id, err := compass.CreateScorecard(change.Desired)
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			fmt.Printf("Created scorecard '%s' (ID: %s)\n", change.Desired.Name, id)
+		case definitions.ActionUpdate:
+			if err := compass.UpdateScorecard(change.Desired, change.Removed); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			fmt.Printf("Updated scorecard '%s'\n", change.Desired.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d scorecards failed to sync:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFetcher downloads repository snapshots through the GitHub archive
+// API. It needs neither git nor history and works in minimal containers.
+type ArchiveFetcher struct {
+	token   string
+	options CheckoutOptions
+	client  *http.Client
+}
+
+func NewArchiveFetcher(token string, options CheckoutOptions) *ArchiveFetcher {
+	return &ArchiveFetcher{token: token, options: options, client: NewHTTPClient()}
+}
+
+func (af *ArchiveFetcher) Clone(info *GitInfo, repoPath string, paths []string) (string, error) {
+	if info == nil || info.Owner == "" || info.Repo == "" || repoPath == "" {
+		return "", fmt.Errorf("owner, repo, and destination are required")
+	}
+
+	ref := info.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	// Pin the ref first so the snapshot and the reported SHA always agree
+	repoAPI := fmt.Sprintf("%s/repos/%s/%s", gitHubAPIBase(gitHost(info)), info.Owner, info.Repo)
+	sha, err := af.get(repoAPI+"/commits/"+ref, "application/vnd.github.sha", info)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ref '%s': %w", ref, err)
+	}
+	body, err := io.ReadAll(io.LimitReader(sha, 128))
+	_ = sha.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ref '%s': %w", ref, err)
+	}
+	commit := strings.TrimSpace(string(body))
+
+	if !info.Before.IsZero() {
+		if commit, err = af.commitBefore(repoAPI, commit, info); err != nil {
+			return "", err
+		}
+	}
+
+	tarball, err := af.get(repoAPI+"/tarball/"+commit, "application/vnd.github+json", info)
+	if err != nil {
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Printf("failed to close response body: %v\n", err)
+		}
+	}(tarball)
+
+	if err := os.RemoveAll(repoPath); err != nil {
+		return "", fmt.Errorf("failed to remove existing directory: %w", err)
+	}
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	var include []string
+	if af.options.Sparse {
+		include = paths
+	}
+	if err := extractTarball(tarball, repoPath, include); err != nil {
+		return "", fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	return commit, nil
+}
+
+func (af *ArchiveFetcher) get(url, accept string, info *GitInfo) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	if token := tokenForHost(gitHost(info), af.token); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := af.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
+
+// commitBefore returns the last commit reachable from tip made before info.Before.
+func (af *ArchiveFetcher) commitBefore(repoAPI, tip string, info *GitInfo) (string, error) {
+	query := url.Values{}
+	query.Set("sha", tip)
+	query.Set("until", info.Before.UTC().Format(time.RFC3339))
+	query.Set("per_page", "1")
+	body, err := af.get(repoAPI+"/commits?"+query.Encode(), "application/vnd.github+json", info)
+	if err != nil {
+		return "", fmt.Errorf("failed to list commits before %s: %w", info.Before.Format(time.RFC3339), err)
+	}
+	defer func() { _ = body.Close() }()
+
+	var commits []struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(body).Decode(&commits); err != nil {
+		return "", fmt.Errorf("failed to parse commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commit before %s", info.Before.Format(time.RFC3339))
+	}
+	return commits[0].SHA, nil
+}
+
+func gitHubAPIBase(host string) string {
+	if host == DefaultGitHost {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// extractTarball unpacks a GitHub archive into dst, dropping the top-level
+// "<owner>-<repo>-<sha>/" directory. When include is non-empty only entries
+// at or below one of those paths are written.
+func extractTarball(r io.Reader, dst string, include []string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		} else {
+			continue // the top-level directory itself
+		}
+		if name == "" || strings.HasPrefix(name, "../") || !includedPath(name, include) {
+			continue
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func includedPath(name string, include []string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, p := range include {
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		if name == p || strings.HasPrefix(name, p+"/") || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
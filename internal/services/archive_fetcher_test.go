@@ -0,0 +1,68 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// githubStub answers GitHub API requests from canned bodies keyed by path.
+type githubStub map[string][]byte
+
+func (g githubStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := g[req.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status, body = http.StatusNotFound, []byte("not found")
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(body)), Request: req}, nil
+}
+
+// tarball builds a GitHub style archive of files below a top-level directory.
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: "motain-svc-abc123/" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFetcherTrimsResolvedSHA(t *testing.T) {
+	stub := githubStub{
+		"/repos/motain/svc/commits/main":   []byte("abc123\n"),
+		"/repos/motain/svc/tarball/abc123": tarball(t, map[string]string{"coverage.json": "{}"}),
+	}
+	fetcher := &ArchiveFetcher{client: &http.Client{Transport: stub}}
+	dir := filepath.Join(t.TempDir(), "svc")
+
+	commit, err := fetcher.Clone(&GitInfo{Host: DefaultGitHost, Owner: "motain", Repo: "svc", Branch: "main"}, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != "abc123" {
+		t.Errorf("Clone() = %q, want abc123", commit)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "coverage.json")); err != nil {
+		t.Errorf("archive not extracted: %v", err)
+	}
+}
@@ -1,33 +1,9 @@
 package services
 
-var getAllComponentQuery = `
-		query searchComponents($cloudId: String!, $query: CompassSearchComponentQuery!) {
-			compass {
-			  searchComponents(cloudId: $cloudId, query: $query) {
-				... on CompassSearchComponentConnection {
-				  nodes {
-					component {
-					  name
-					}
-				  }
-				}
-			  }
-			}
-		  }`
-
-var getAllComponentResponse struct {
-	Data struct {
-		Compass struct {
-			SearchComponents struct {
-				Nodes []struct {
-					Component struct {
-						Name string `json:"name"`
-					} `json:"component"`
-				} `json:"nodes"`
-			} `json:"searchComponents"`
-		} `json:"compass"`
-	} `json:"data"`
-}
+import (
+	"fmt"
+	"strings"
+)
 
 var getComponentQuery = `
 		query getComponent($cloudId: ID!, $slug: String!) {
@@ -35,11 +11,17 @@ var getComponentQuery = `
 				componentByReference(reference: {slug: {slug: $slug, cloudId: $cloudId}}) {
 					... on CompassComponent {
 						id name type
+						links { type url name }
 						metricSources {
 							... on CompassComponentMetricSourcesConnection {
 								nodes {
 									id
 									metricDefinition { name id }
+									values(query: {first: 1}) {
+										... on CompassMetricSourceValuesConnection {
+											nodes { value timestamp }
+										}
+									}
 								}
 							}
 						}
@@ -48,13 +30,18 @@ var getComponentQuery = `
 			}
 		}`
 
-var getComponentResponse struct {
+type getComponentResponse struct {
 	Data struct {
 		Compass struct {
 			ComponentByReference struct {
-				ID            string `json:"id"`
-				Name          string `json:"name"`
-				Type          string `json:"type"`
+				ID    string `json:"id"`
+				Name  string `json:"name"`
+				Type  string `json:"type"`
+				Links []struct {
+					Type string `json:"type"`
+					URL  string `json:"url"`
+					Name string `json:"name"`
+				} `json:"links"`
 				MetricSources struct {
 					Nodes []struct {
 						ID               string `json:"id"`
@@ -62,9 +49,368 @@ var getComponentResponse struct {
 							Name string `json:"name"`
 							ID   string `json:"id"`
 						} `json:"metricDefinition"`
+						Values struct {
+							Nodes []MetricValue `json:"nodes"`
+						} `json:"values"`
 					} `json:"nodes"`
 				} `json:"metricSources"`
 			} `json:"componentByReference"`
 		} `json:"compass"`
 	} `json:"data"`
 }
+
+// graphqlError is an error reported in the payload of a mutation.
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+var getMetricDefinitionsQuery = `
+		query metricDefinitions($cloudId: ID!, $first: Int, $after: String) {
+			compass {
+				metricDefinitions(query: {cloudId: $cloudId, first: $first, after: $after}) {
+					... on CompassMetricDefinitionsConnection {
+						nodes {
+							id name description type
+							format {
+								... on CompassMetricDefinitionFormatSuffix { suffix }
+							}
+						}
+						pageInfo { hasNextPage endCursor }
+					}
+					... on QueryError { message }
+				}
+			}
+		}`
+
+type getMetricDefinitionsResponse struct {
+	Data struct {
+		Compass struct {
+			MetricDefinitions struct {
+				Nodes []struct {
+					ID          string `json:"id"`
+					Name        string `json:"name"`
+					Description string `json:"description"`
+					Type        string `json:"type"`
+					Format      struct {
+						Suffix string `json:"suffix"`
+					} `json:"format"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Message string `json:"message"`
+			} `json:"metricDefinitions"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var createMetricDefinitionMutation = `
+		mutation createMetricDefinition($input: CompassCreateMetricDefinitionInput!) {
+			compass {
+				createMetricDefinition(input: $input) {
+					success
+					errors { message }
+					createdMetricDefinition { id name }
+				}
+			}
+		}`
+
+type createMetricDefinitionResponse struct {
+	Data struct {
+		Compass struct {
+			CreateMetricDefinition struct {
+				Success                 bool           `json:"success"`
+				Errors                  []graphqlError `json:"errors"`
+				CreatedMetricDefinition struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"createdMetricDefinition"`
+			} `json:"createMetricDefinition"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var updateMetricDefinitionMutation = `
+		mutation updateMetricDefinition($input: CompassUpdateMetricDefinitionInput!) {
+			compass {
+				updateMetricDefinition(input: $input) {
+					success
+					errors { message }
+					updatedMetricDefinition { id name }
+				}
+			}
+		}`
+
+type updateMetricDefinitionResponse struct {
+	Data struct {
+		Compass struct {
+			UpdateMetricDefinition struct {
+				Success bool           `json:"success"`
+				Errors  []graphqlError `json:"errors"`
+			} `json:"updateMetricDefinition"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var listComponentsQuery = `
+		query listComponents($cloudId: String!, $query: CompassSearchComponentQuery!) {
+			compass {
+				searchComponents(cloudId: $cloudId, query: $query) {
+					... on CompassSearchComponentConnection {
+						nodes {
+							component {
+								id name slug type
+								metricSources {
+									... on CompassComponentMetricSourcesConnection {
+										nodes {
+											id
+											metricDefinition { name id }
+										}
+									}
+								}
+							}
+						}
+						pageInfo { hasNextPage endCursor }
+					}
+					... on QueryError { message }
+				}
+			}
+		}`
+
+type listComponentsResponse struct {
+	Data struct {
+		Compass struct {
+			SearchComponents struct {
+				Nodes []struct {
+					Component struct {
+						ID            string `json:"id"`
+						Name          string `json:"name"`
+						Slug          string `json:"slug"`
+						Type          string `json:"type"`
+						MetricSources struct {
+							Nodes []struct {
+								ID               string `json:"id"`
+								MetricDefinition struct {
+									Name string `json:"name"`
+									ID   string `json:"id"`
+								} `json:"metricDefinition"`
+							} `json:"nodes"`
+						} `json:"metricSources"`
+					} `json:"component"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Message string `json:"message"`
+			} `json:"searchComponents"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var searchTeamsQuery = `
+		query searchTeams($cloudId: ID!, $term: String!, $first: Int, $after: String) {
+			compass {
+				searchTeams(input: {cloudId: $cloudId, term: $term, first: $first, after: $after}) {
+					... on CompassSearchTeamsConnection {
+						nodes {
+							team { id displayName }
+						}
+						pageInfo { hasNextPage endCursor }
+					}
+					... on QueryError { message }
+				}
+			}
+		}`
+
+type searchTeamsResponse struct {
+	Data struct {
+		Compass struct {
+			SearchTeams struct {
+				Nodes []struct {
+					Team struct {
+						ID          string `json:"id"`
+						DisplayName string `json:"displayName"`
+					} `json:"team"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Message string `json:"message"`
+			} `json:"searchTeams"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var createMetricSourceMutation = `
+		mutation createMetricSource($input: CompassCreateMetricSourceInput!) {
+			compass {
+				createMetricSource(input: $input) {
+					success
+					errors { message }
+					createdMetricSource { id }
+				}
+			}
+		}`
+
+type createMetricSourceResponse struct {
+	Data struct {
+		Compass struct {
+			CreateMetricSource struct {
+				Success             bool           `json:"success"`
+				Errors              []graphqlError `json:"errors"`
+				CreatedMetricSource struct {
+					ID string `json:"id"`
+				} `json:"createdMetricSource"`
+			} `json:"createMetricSource"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var deleteMetricSourceMutation = `
+		mutation deleteMetricSource($input: CompassDeleteMetricSourceInput!) {
+			compass {
+				deleteMetricSource(input: $input) {
+					success
+					errors { message }
+				}
+			}
+		}`
+
+type deleteMetricSourceResponse struct {
+	Data struct {
+		Compass struct {
+			DeleteMetricSource struct {
+				Success bool           `json:"success"`
+				Errors  []graphqlError `json:"errors"`
+			} `json:"deleteMetricSource"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+// insertMetricValuesMutation inserts n metric values in one request. Each value
+// is an aliased mutation (m0, m1, ...) with its own input variable (i0, i1,
+// ...), so Compass reports success per value.
+func insertMetricValuesMutation(n int) string {
+	var declarations, fields strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			declarations.WriteString(", ")
+		}
+		fmt.Fprintf(&declarations, "$i%d: CompassInsertMetricValueInput!", i)
+		fmt.Fprintf(&fields, "\t\t\t\tm%d: insertMetricValue(input: $i%d) { success errors { message } }\n", i, i)
+	}
+	return fmt.Sprintf("\n\t\tmutation insertMetricValues(%s) {\n\t\t\tcompass {\n%s\t\t\t}\n\t\t}", declarations.String(), fields.String())
+}
+
+type insertMetricValuesResponse struct {
+	Data struct {
+		Compass map[string]struct {
+			Success bool           `json:"success"`
+			Errors  []graphqlError `json:"errors"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var getScorecardsQuery = `
+		query scorecards($cloudId: ID!, $first: Int, $after: String) {
+			compass {
+				scorecards(cloudId: $cloudId, query: {first: $first, after: $after}) {
+					... on CompassScorecardConnection {
+						nodes {
+							id
+							name
+							description
+							importance
+							componentTypeIds
+							owner { id }
+							criterias {
+								id
+								weight
+								... on CompassHasMetricValueScorecardCriteria {
+									name
+									metricDefinitionId
+									comparator
+									comparatorValue
+								}
+							}
+						}
+						pageInfo { hasNextPage endCursor }
+					}
+					... on QueryError { message }
+				}
+			}
+		}`
+
+type getScorecardsResponse struct {
+	Data struct {
+		Compass struct {
+			Scorecards struct {
+				Nodes []struct {
+					ID               string   `json:"id"`
+					Name             string   `json:"name"`
+					Description      string   `json:"description"`
+					Importance       string   `json:"importance"`
+					ComponentTypeIDs []string `json:"componentTypeIds"`
+					Owner            *struct {
+						ID string `json:"id"`
+					} `json:"owner"`
+					Criterias []CompassScorecardCriterion `json:"criterias"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Message string `json:"message"`
+			} `json:"scorecards"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var createScorecardMutation = `
+		mutation createScorecard($cloudId: ID!, $input: CreateCompassScorecardInput!) {
+			compass {
+				createScorecard(cloudId: $cloudId, input: $input) {
+					success
+					errors { message }
+					scorecardDetails { id }
+				}
+			}
+		}`
+
+type createScorecardResponse struct {
+	Data struct {
+		Compass struct {
+			CreateScorecard struct {
+				Success          bool           `json:"success"`
+				Errors           []graphqlError `json:"errors"`
+				ScorecardDetails struct {
+					ID string `json:"id"`
+				} `json:"scorecardDetails"`
+			} `json:"createScorecard"`
+		} `json:"compass"`
+	} `json:"data"`
+}
+
+var updateScorecardMutation = `
+		mutation updateScorecard($scorecardId: ID!, $input: UpdateCompassScorecardInput!) {
+			compass {
+				updateScorecard(scorecardId: $scorecardId, input: $input) {
+					success
+					errors { message }
+				}
+			}
+		}`
+
+type updateScorecardResponse struct {
+	Data struct {
+		Compass struct {
+			UpdateScorecard struct {
+				Success bool           `json:"success"`
+				Errors  []graphqlError `json:"errors"`
+			} `json:"updateScorecard"`
+		} `json:"compass"`
+	} `json:"data"`
+}
@@ -2,26 +2,54 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// CompassClient is the part of the Compass API this tool uses. CompassService
+// implements it against the real API; tests can substitute a fake.
+type CompassClient interface {
+	GetComponent(name string) (*Component, error)
+	ListComponents(filter ComponentFilter) ([]Component, error)
+	SearchTeams(term string) ([]Team, error)
+	PutMetrics(submissions []MetricSubmission) []error
+	GetMetricDefinitions() ([]CompassMetricDefinition, error)
+	CreateMetricDefinition(definition CompassMetricDefinition) (string, error)
+	UpdateMetricDefinition(definition CompassMetricDefinition) error
+	CreateMetricSource(componentID, metricDefinitionID string) (string, error)
+	DeleteMetricSource(sourceID string) error
+	GetScorecards() ([]CompassScorecard, error)
+	CreateScorecard(scorecard CompassScorecard) (string, error)
+	UpdateScorecard(scorecard CompassScorecard, removedCriteria []string) error
+}
+
+var _ CompassClient = (*CompassService)(nil)
+
 type CompassService struct {
 	token   string
 	cloudID string
+	config  *Config
 	client  *http.Client
 }
 
 func NewCompassService() *CompassService {
+	return NewCompassServiceWithClient(NewHTTPClient())
+}
+
+// NewCompassServiceWithClient returns a service sending its requests through client.
+func NewCompassServiceWithClient(client *http.Client) *CompassService {
+	cfg := CurrentConfig()
 	return &CompassService{
-		token:   os.Getenv("COMPASS_API_TOKEN"),
-		cloudID: os.Getenv("COMPASS_CLOUD_ID"),
-		client:  &http.Client{Timeout: 30 * time.Second},
+		token:   cfg.CompassAPIToken,
+		cloudID: cfg.CompassCloudID,
+		config:  cfg,
+		client:  client,
 	}
 }
 
@@ -29,7 +57,7 @@ func (cs *CompassService) GetComponent(name string) (*Component, error) {
 
 	variables := map[string]interface{}{
 		"cloudId": cs.cloudID,
-		"slug":    ServiceSlugPrefix + name,
+		"slug":    cs.config.ServiceSlugPrefix + name,
 	}
 
 	respData, err := cs.graphqlRequest(getComponentQuery, variables)
@@ -37,11 +65,12 @@ func (cs *CompassService) GetComponent(name string) (*Component, error) {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(respData, &getComponentResponse); err != nil {
+	var response getComponentResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	comp := getComponentResponse.Data.Compass.ComponentByReference
+	comp := response.Data.Compass.ComponentByReference
 	if comp.ID == "" {
 		return nil, fmt.Errorf("component not found: %s", name)
 	}
@@ -49,52 +78,97 @@ func (cs *CompassService) GetComponent(name string) (*Component, error) {
 	var metrics []Metric
 	for _, node := range comp.MetricSources.Nodes {
 		if !strings.Contains(node.MetricDefinition.ID, "builtin") {
-			metrics = append(metrics, Metric{
+			metric := Metric{
 				Name:         node.MetricDefinition.Name,
 				DefinitionID: node.MetricDefinition.ID,
 				SourceID:     node.ID,
-			})
+			}
+			// Compass lists values newest first; don't rely on it for the comparison
+			for _, value := range node.Values.Nodes {
+				if metric.Latest == nil || value.Timestamp.After(metric.Latest.Timestamp) {
+					latest := value
+					metric.Latest = &latest
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+
+	// Components without a usable repository link fall back to <org>/<name> on github.com
+	repository := &GitInfo{Host: DefaultGitHost, Owner: cs.config.GitHubOrg, Repo: name}
+	for _, link := range comp.Links {
+		if link.Type != "REPOSITORY" {
+			continue
+		}
+		if info, err := ParseRepositoryURL(link.URL); err == nil {
+			repository = info
+			break
 		}
 	}
 
 	return &Component{
-		Name:    name,
-		ID:      comp.ID,
-		Type:    comp.Type,
-		Metrics: metrics,
+		Name:       name,
+		ID:         comp.ID,
+		Type:       comp.Type,
+		Repository: repository,
+		Metrics:    metrics,
 	}, nil
 }
 
-func (cs *CompassService) PutMetric(componentID, metricDefinitionID, value string) error {
-	payload := map[string]string{
-		"metricDefinitionId": metricDefinitionID,
-		"value":              value,
-		"timestamp":          time.Now().UTC().Format(time.RFC3339),
-		"componentId":        componentID,
+// PutMetrics stores several metric values in one GraphQL request and returns
+// one error per submission, nil for the values Compass accepted. When the
+// request itself fails every submission gets its error.
+func (cs *CompassService) PutMetrics(submissions []MetricSubmission) []error {
+	errs := make([]error, len(submissions))
+
+	// Values Compass would reject are not sent
+	var sent []int
+	variables := make(map[string]interface{})
+	for i, submission := range submissions {
+		value, err := strconv.ParseFloat(submission.Value, 64)
+		if err != nil {
+			errs[i] = fmt.Errorf("value '%s' is not a number", submission.Value)
+			continue
+		}
+		if submission.MetricSourceID == "" {
+			errs[i] = fmt.Errorf("no metric source for metric definition %s", submission.MetricDefinitionID)
+			continue
+		}
+		variables[fmt.Sprintf("i%d", len(sent))] = map[string]interface{}{
+			"metricSourceId": submission.MetricSourceID,
+			"value": map[string]interface{}{
+				"value":     value,
+				"timestamp": submission.Timestamp.UTC().Format(time.RFC3339),
+			},
+		}
+		sent = append(sent, i)
 	}
-
-	_, err := cs.httpRequest("POST", MetricsEndpoint, payload)
-	return err
-}
-
-func (cs *CompassService) GetMetricFacts(metricName, componentType string) ([]Fact, error) {
-	parser := NewMetricsParser(GetMetricLocalPath())
-	metrics, err := parser.ParseMetrics()
-	if err != nil {
-		return nil, err
+	if len(sent) == 0 {
+		return errs
 	}
 
-	for _, metric := range metrics {
-		if metric.Metadata.Name == metricName {
-			for _, ct := range metric.Metadata.ComponentType {
-				if strings.EqualFold(ct, componentType) {
-					return metric.Metadata.Facts, nil
-				}
-			}
+	respData, err := cs.graphqlRequest(insertMetricValuesMutation(len(sent)), variables)
+	var response insertMetricValuesResponse
+	if err == nil {
+		if err = json.Unmarshal(respData, &response); err != nil {
+			err = fmt.Errorf("failed to parse response: %w", err)
 		}
 	}
-
-	return nil, fmt.Errorf("no facts found for metric '%s' and type '%s'", metricName, componentType)
+	for n, i := range sent {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		// Only a result reporting failure proves the value was not stored
+		result, ok := response.Data.Compass[fmt.Sprintf("m%d", n)]
+		switch {
+		case !ok:
+			errs[i] = fmt.Errorf("failed to insert metric value: no result in the response")
+		case !result.Success:
+			errs[i] = &RetryableError{Err: fmt.Errorf("failed to insert metric value: %s", mutationErrors(result.Errors))}
+		}
+	}
+	return errs
 }
 
 func (cs *CompassService) graphqlRequest(query string, variables map[string]interface{}) ([]byte, error) {
@@ -103,7 +177,12 @@ func (cs *CompassService) graphqlRequest(query string, variables map[string]inte
 		"variables": variables,
 	}
 
-	respData, err := cs.httpRequest("POST", GraphQLEndpoint, reqBody)
+	// Queries may be retried after a timeout; mutations, which Compass would apply twice, may not
+	ctx := context.Background()
+	if strings.HasPrefix(strings.TrimSpace(query), "query") {
+		ctx = WithIdempotent(ctx)
+	}
+	respData, err := cs.httpRequest(ctx, "POST", cs.config.GraphQLEndpoint(), reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +200,7 @@ func (cs *CompassService) graphqlRequest(query string, variables map[string]inte
 	return respData, nil
 }
 
-func (cs *CompassService) httpRequest(method, url string, payload interface{}) ([]byte, error) {
+func (cs *CompassService) httpRequest(ctx context.Context, method, url string, payload interface{}) ([]byte, error) {
 	var body io.Reader
 	if payload != nil {
 		data, err := json.Marshal(payload)
@@ -131,7 +210,7 @@ func (cs *CompassService) httpRequest(method, url string, payload interface{}) (
 		body = bytes.NewBuffer(data)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -142,7 +221,11 @@ func (cs *CompassService) httpRequest(method, url string, payload interface{}) (
 
 	resp, err := cs.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		err = fmt.Errorf("request failed: %w", err)
+		if dialFailed(err) {
+			return nil, &RetryableError{Err: err}
+		}
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -157,42 +240,435 @@ func (cs *CompassService) httpRequest(method, url string, payload interface{}) (
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respData))
+		err := fmt.Errorf("API error %d: %s", resp.StatusCode, string(respData))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &RetryableError{Err: err}
+		}
+		return nil, err
 	}
 
 	return respData, nil
 }
 
-func (cs *CompassService) GetAllComponentList() ([]string, error) {
+// GetMetricDefinitions lists every metric definition of the Compass site.
+func (cs *CompassService) GetMetricDefinitions() ([]CompassMetricDefinition, error) {
+	var definitions []CompassMetricDefinition
+	after := ""
 
-	variables := map[string]interface{}{
-		"cloudId": cs.cloudID,
-		"query": map[string]interface{}{
-			"first": 200,
-			"fieldFilters": map[string]interface{}{
-				"name": "state",
-				"filter": map[string]interface{}{
-					"neq": "PENDING",
-				},
-			},
+	for {
+		variables := map[string]interface{}{
+			"cloudId": cs.cloudID,
+			"first":   100,
+		}
+		if after != "" {
+			variables["after"] = after
+		}
+
+		respData, err := cs.graphqlRequest(getMetricDefinitionsQuery, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		var response getMetricDefinitionsResponse
+		if err := json.Unmarshal(respData, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		page := response.Data.Compass.MetricDefinitions
+		if page.Message != "" {
+			return nil, fmt.Errorf("failed to list metric definitions: %s", page.Message)
+		}
+		for _, node := range page.Nodes {
+			definitions = append(definitions, CompassMetricDefinition{
+				ID:          node.ID,
+				Name:        node.Name,
+				Description: node.Description,
+				Unit:        node.Format.Suffix,
+				BuiltIn:     node.Type == "BUILT_IN" || strings.Contains(node.ID, "builtin"),
+			})
+		}
+
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return definitions, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+// CreateMetricDefinition creates a user defined metric definition and returns its ID.
+func (cs *CompassService) CreateMetricDefinition(definition CompassMetricDefinition) (string, error) {
+	input := cs.metricDefinitionInput(definition)
+
+	respData, err := cs.graphqlRequest(createMetricDefinitionMutation, map[string]interface{}{"input": input})
+	if err != nil {
+		return "", err
+	}
+
+	var response createMetricDefinitionResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := response.Data.Compass.CreateMetricDefinition
+	if !result.Success {
+		return "", fmt.Errorf("failed to create metric definition '%s': %s", definition.Name, mutationErrors(result.Errors))
+	}
+	return result.CreatedMetricDefinition.ID, nil
+}
+
+// UpdateMetricDefinition updates the name, description and unit of definition.ID.
+func (cs *CompassService) UpdateMetricDefinition(definition CompassMetricDefinition) error {
+	input := cs.metricDefinitionInput(definition)
+	input["id"] = definition.ID
+
+	respData, err := cs.graphqlRequest(updateMetricDefinitionMutation, map[string]interface{}{"input": input})
+	if err != nil {
+		return err
+	}
+
+	var response updateMetricDefinitionResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := response.Data.Compass.UpdateMetricDefinition
+	if !result.Success {
+		return fmt.Errorf("failed to update metric definition '%s': %s", definition.Name, mutationErrors(result.Errors))
+	}
+	return nil
+}
+
+// metricDefinitionInput always sends the format: an update without one keeps
+// the unit Compass has, so a unit removed from the YAML would never be cleared.
+func (cs *CompassService) metricDefinitionInput(definition CompassMetricDefinition) map[string]interface{} {
+	return map[string]interface{}{
+		"cloudId":     cs.cloudID,
+		"name":        definition.Name,
+		"description": definition.Description,
+		"format": map[string]interface{}{
+			"suffix": map[string]interface{}{"suffix": definition.Unit},
 		},
 	}
+}
+
+func mutationErrors(errors []graphqlError) string {
+	if len(errors) == 0 {
+		return "unknown error"
+	}
+	messages := make([]string, len(errors))
+	for i, e := range errors {
+		messages[i] = e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ListComponents returns every active component matching filter with its
+// type and metric sources, following the search pagination to the end.
+// Components are named by their slug without ServiceSlugPrefix, as
+// GetComponent expects; those without such a slug are skipped.
+func (cs *CompassService) ListComponents(filter ComponentFilter) ([]Component, error) {
+	var components []Component
+	after := ""
+
+	for {
+		query := map[string]interface{}{
+			"first":        100,
+			"fieldFilters": filter.fieldFilters(),
+		}
+		if after != "" {
+			query["after"] = after
+		}
+
+		respData, err := cs.graphqlRequest(listComponentsQuery, map[string]interface{}{
+			"cloudId": cs.cloudID,
+			"query":   query,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var response listComponentsResponse
+		if err := json.Unmarshal(respData, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		page := response.Data.Compass.SearchComponents
+		if page.Message != "" {
+			return nil, fmt.Errorf("failed to list components: %s", page.Message)
+		}
+		for _, node := range page.Nodes {
+			comp := node.Component
+			// Components are looked up by slug, so that is the name runs must use
+			name, ok := strings.CutPrefix(comp.Slug, cs.config.ServiceSlugPrefix)
+			if !ok || name == "" {
+				fmt.Printf("Warning: skipping component '%s': slug '%s' does not start with '%s'\n", comp.Name, comp.Slug, cs.config.ServiceSlugPrefix)
+				continue
+			}
+			component := Component{Name: name, ID: comp.ID, Type: comp.Type}
+			for _, source := range comp.MetricSources.Nodes {
+				component.Metrics = append(component.Metrics, Metric{
+					Name:         source.MetricDefinition.Name,
+					DefinitionID: source.MetricDefinition.ID,
+					SourceID:     source.ID,
+				})
+			}
+			components = append(components, component)
+		}
+
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return components, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+// SearchTeams returns the teams whose name matches term, following the
+// search pagination to the end.
+func (cs *CompassService) SearchTeams(term string) ([]Team, error) {
+	var teams []Team
+	after := ""
+
+	for {
+		variables := map[string]interface{}{
+			"cloudId": cs.cloudID,
+			"term":    term,
+			"first":   100,
+		}
+		if after != "" {
+			variables["after"] = after
+		}
 
-	respData, err := cs.graphqlRequest(getAllComponentQuery, variables)
+		respData, err := cs.graphqlRequest(searchTeamsQuery, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		var response searchTeamsResponse
+		if err := json.Unmarshal(respData, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		page := response.Data.Compass.SearchTeams
+		if page.Message != "" {
+			return nil, fmt.Errorf("failed to search teams: %s", page.Message)
+		}
+		for _, node := range page.Nodes {
+			teams = append(teams, Team{ID: node.Team.ID, Name: node.Team.DisplayName})
+		}
+
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return teams, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+// CreateMetricSource connects a metric definition to a component so values
+// can be submitted for it, and returns the ID of the new metric source.
+func (cs *CompassService) CreateMetricSource(componentID, metricDefinitionID string) (string, error) {
+	input := map[string]interface{}{
+		"componentId":        componentID,
+		"metricDefinitionId": metricDefinitionID,
+	}
+
+	respData, err := cs.graphqlRequest(createMetricSourceMutation, map[string]interface{}{"input": input})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if err := json.Unmarshal(respData, &getAllComponentResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	var response createMetricSourceResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := response.Data.Compass.CreateMetricSource
+	if !result.Success {
+		return "", fmt.Errorf("failed to create metric source: %s", mutationErrors(result.Errors))
+	}
+	return result.CreatedMetricSource.ID, nil
+}
+
+// DeleteMetricSource disconnects a metric definition from a component.
+func (cs *CompassService) DeleteMetricSource(sourceID string) error {
+	respData, err := cs.graphqlRequest(deleteMetricSourceMutation, map[string]interface{}{
+		"input": map[string]interface{}{"id": sourceID},
+	})
+	if err != nil {
+		return err
 	}
 
-	comp := getAllComponentResponse.Data.Compass.SearchComponents
+	var response deleteMetricSourceResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
 
-	var componentList []string
-	for _, node := range comp.Nodes {
-		componentList = append(componentList, node.Component.Name)
+	result := response.Data.Compass.DeleteMetricSource
+	if !result.Success {
+		return fmt.Errorf("failed to delete metric source: %s", mutationErrors(result.Errors))
 	}
+	return nil
+}
 
-	return componentList, nil
+// fieldFilters translates the filter into searchComponents field filters,
+// which Compass combines with AND.
+func (f ComponentFilter) fieldFilters() []map[string]interface{} {
+	filters := []map[string]interface{}{
+		{"name": "state", "filter": map[string]interface{}{"neq": "PENDING"}},
+	}
+	if len(f.Types) > 0 {
+		types := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			types[i] = strings.ToUpper(t)
+		}
+		filters = append(filters, map[string]interface{}{"name": "type", "filter": map[string]interface{}{"in": types}})
+	}
+	for _, label := range f.Labels {
+		filters = append(filters, map[string]interface{}{"name": "labels", "filter": map[string]interface{}{"in": []string{label}}})
+	}
+	if f.OwnerID != "" {
+		filters = append(filters, map[string]interface{}{"name": "ownerId", "filter": map[string]interface{}{"eq": f.OwnerID}})
+	}
+	return filters
+}
+
+// GetScorecards lists every scorecard of the Compass site.
+func (cs *CompassService) GetScorecards() ([]CompassScorecard, error) {
+	var scorecards []CompassScorecard
+	after := ""
+
+	for {
+		variables := map[string]interface{}{
+			"cloudId": cs.cloudID,
+			"first":   100,
+		}
+		if after != "" {
+			variables["after"] = after
+		}
+
+		respData, err := cs.graphqlRequest(getScorecardsQuery, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		var response getScorecardsResponse
+		if err := json.Unmarshal(respData, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		page := response.Data.Compass.Scorecards
+		if page.Message != "" {
+			return nil, fmt.Errorf("failed to list scorecards: %s", page.Message)
+		}
+		for _, node := range page.Nodes {
+			scorecard := CompassScorecard{
+				ID:             node.ID,
+				Name:           node.Name,
+				Description:    node.Description,
+				Importance:     node.Importance,
+				ComponentTypes: node.ComponentTypeIDs,
+				Criteria:       node.Criterias,
+			}
+			if node.Owner != nil {
+				scorecard.OwnerID = node.Owner.ID
+			}
+			scorecards = append(scorecards, scorecard)
+		}
+
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return scorecards, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+// CreateScorecard creates a scorecard with its criteria and returns its ID.
+func (cs *CompassService) CreateScorecard(scorecard CompassScorecard) (string, error) {
+	input := scorecardInput(scorecard)
+	input["criterias"] = criteriaInput(scorecard.Criteria)
+
+	respData, err := cs.graphqlRequest(createScorecardMutation, map[string]interface{}{"cloudId": cs.cloudID, "input": input})
+	if err != nil {
+		return "", err
+	}
+
+	var response createScorecardResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := response.Data.Compass.CreateScorecard
+	if !result.Success {
+		return "", fmt.Errorf("failed to create scorecard '%s': %s", scorecard.Name, mutationErrors(result.Errors))
+	}
+	return result.ScorecardDetails.ID, nil
+}
+
+// UpdateScorecard updates scorecard.ID: criteria with an ID are updated, the
+// others created, and the criteria in removedCriteria deleted.
+func (cs *CompassService) UpdateScorecard(scorecard CompassScorecard, removedCriteria []string) error {
+	input := scorecardInput(scorecard)
+	var created, updated []CompassScorecardCriterion
+	for _, criterion := range scorecard.Criteria {
+		if criterion.ID == "" {
+			created = append(created, criterion)
+		} else {
+			updated = append(updated, criterion)
+		}
+	}
+	input["createCriteria"] = criteriaInput(created)
+	input["updateCriteria"] = criteriaInput(updated)
+	deleted := make([]map[string]interface{}, len(removedCriteria))
+	for i, id := range removedCriteria {
+		deleted[i] = map[string]interface{}{"id": id}
+	}
+	input["deleteCriteria"] = deleted
+
+	respData, err := cs.graphqlRequest(updateScorecardMutation, map[string]interface{}{"scorecardId": scorecard.ID, "input": input})
+	if err != nil {
+		return err
+	}
+
+	var response updateScorecardResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := response.Data.Compass.UpdateScorecard
+	if !result.Success {
+		return fmt.Errorf("failed to update scorecard '%s': %s", scorecard.Name, mutationErrors(result.Errors))
+	}
+	return nil
+}
+
+func scorecardInput(scorecard CompassScorecard) map[string]interface{} {
+	input := map[string]interface{}{
+		"name":             scorecard.Name,
+		"description":      scorecard.Description,
+		"importance":       scorecard.Importance,
+		"componentTypeIds": scorecard.ComponentTypes,
+	}
+	if scorecard.OwnerID != "" {
+		input["ownerId"] = scorecard.OwnerID
+	}
+	return input
+}
+
+// criteriaInput renders "has metric value" criteria; criteria with an ID are
+// updates of existing ones.
+func criteriaInput(criteria []CompassScorecardCriterion) []map[string]interface{} {
+	inputs := make([]map[string]interface{}, len(criteria))
+	for i, criterion := range criteria {
+		value := map[string]interface{}{
+			"name":               criterion.Name,
+			"weight":             criterion.Weight,
+			"metricDefinitionId": criterion.MetricDefinitionID,
+			"comparator":         criterion.Comparator,
+			"comparatorValue":    criterion.ComparatorValue,
+		}
+		if criterion.ID != "" {
+			value["id"] = criterion.ID
+		}
+		inputs[i] = map[string]interface{}{"hasMetricValue": value}
+	}
+	return inputs
 }
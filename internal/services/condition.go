@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Comparison operators of conditions on metric values, with the Compass
+// scorecard comparator each corresponds to.
+var comparators = map[string]string{
+	">=": "GREATER_THAN_OR_EQUAL_TO",
+	">":  "GREATER_THAN",
+	"<=": "LESS_THAN_OR_EQUAL_TO",
+	"<":  "LESS_THAN",
+	"==": "EQUALS",
+}
+
+// Condition compares a metric value with a fixed value.
+type Condition struct {
+	Operator string  `yaml:"operator" json:"operator" schema:"required,enum=>=|>|<=|<|==" desc:"How the metric value is compared with value"`
+	Value    float64 `yaml:"value" json:"value" desc:"Value the metric value is compared with"`
+}
+
+// Validate reports unknown operators.
+func (c Condition) Validate() error {
+	if _, ok := comparators[c.Operator]; !ok {
+		return fmt.Errorf("unknown operator '%s', expected one of >=, >, <=, <, ==", c.Operator)
+	}
+	return nil
+}
+
+// Matches reports whether value satisfies the condition.
+func (c Condition) Matches(value float64) bool {
+	switch c.Operator {
+	case ">=":
+		return value >= c.Value
+	case ">":
+		return value > c.Value
+	case "<=":
+		return value <= c.Value
+	case "<":
+		return value < c.Value
+	case "==":
+		return value == c.Value
+	}
+	return false
+}
+
+// Comparator returns the Compass scorecard comparator of the operator.
+func (c Condition) Comparator() string {
+	return comparators[c.Operator]
+}
+
+func (c Condition) String() string {
+	return c.Operator + " " + strconv.FormatFloat(c.Value, 'f', -1, 64)
+}
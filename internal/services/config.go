@@ -1,26 +1,183 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	CatalogRepo            = "of-catalog"
-	GitHubOrg              = "motain"
-	MetricPath             = "config/grading-system"
-	CompassBaseURL         = "https://onefootball.atlassian.net/gateway/api"
-	GraphQLEndpoint        = CompassBaseURL + "/graphql"
-	MetricsEndpoint        = CompassBaseURL + "/compass/v1/metrics"
-	ServiceSlugPrefix      = "svc-"
-	LocalBasePath          = "./repos/"
-	DefaultMetricLocalPath = LocalBasePath + CatalogRepo + "/" + MetricPath
+	DefaultCatalogRepo       = "of-catalog"
+	DefaultGitHubOrg         = "motain"
+	DefaultGitHost           = "github.com"
+	DefaultMetricPath        = "config/grading-system"
+	DefaultCompassBaseURL    = "https://onefootball.atlassian.net/gateway/api"
+	DefaultServiceSlugPrefix = "svc-"
+	DefaultConfigFile        = "compass-compute.yaml"
 )
 
-func GetMetricLocalPath() string {
-	metricDir := os.Getenv("METRIC_DIR")
-	if metricDir != "" {
-		return filepath.Join(LocalBasePath, "metrics")
+// Config holds the effective settings of a run. It is assembled once at
+// startup from defaults, an optional YAML file, environment variables and
+// command line flags, in increasing order of precedence.
+type Config struct {
+	GitHubOrg         string     `yaml:"githubOrg"`
+	CatalogRepo       string     `yaml:"catalogRepo"`
+	MetricPath        string     `yaml:"metricPath"`
+	MetricDir         string     `yaml:"metricDir,omitempty"`
+	CompassBaseURL    string     `yaml:"compassBaseUrl"`
+	CompassCloudID    string     `yaml:"compassCloudId,omitempty"`
+	ServiceSlugPrefix string     `yaml:"serviceSlugPrefix"`
+	WorkspaceRoot     string     `yaml:"workspaceRoot,omitempty"`
+	StrictMetrics     bool       `yaml:"strictMetrics"`
+	HistoryFile       string     `yaml:"historyFile,omitempty"`
+	HTTP              HTTPConfig `yaml:"http"`
+
+	AWSRegion              string `yaml:"awsRegion,omitempty"`
+	AWSRole                string `yaml:"awsRole,omitempty"`
+	PrometheusWorkspaceURL string `yaml:"prometheusWorkspaceUrl,omitempty"`
+
+	// Secrets; prefer the environment over the config file for these
+	GitHubToken           string `yaml:"githubToken,omitempty"`
+	GitHubEnterpriseToken string `yaml:"githubEnterpriseToken,omitempty"`
+	CompassAPIToken       string `yaml:"compassApiToken,omitempty"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		GitHubOrg:         DefaultGitHubOrg,
+		CatalogRepo:       DefaultCatalogRepo,
+		MetricPath:        DefaultMetricPath,
+		CompassBaseURL:    DefaultCompassBaseURL,
+		ServiceSlugPrefix: DefaultServiceSlugPrefix,
+		HTTP:              DefaultHTTPConfig(),
+	}
+}
+
+// configEnv maps environment variables onto config fields.
+var configEnv = []struct {
+	name  string
+	field func(*Config) *string
+}{
+	{"GITHUB_ORG", func(c *Config) *string { return &c.GitHubOrg }},
+	{"CATALOG_REPO", func(c *Config) *string { return &c.CatalogRepo }},
+	{"METRIC_PATH", func(c *Config) *string { return &c.MetricPath }},
+	{"METRIC_DIR", func(c *Config) *string { return &c.MetricDir }},
+	{"COMPASS_BASE_URL", func(c *Config) *string { return &c.CompassBaseURL }},
+	{"COMPASS_CLOUD_ID", func(c *Config) *string { return &c.CompassCloudID }},
+	{"SERVICE_SLUG_PREFIX", func(c *Config) *string { return &c.ServiceSlugPrefix }},
+	{"WORKSPACE_ROOT", func(c *Config) *string { return &c.WorkspaceRoot }},
+	{"HISTORY_FILE", func(c *Config) *string { return &c.HistoryFile }},
+	{"AWS_REGION", func(c *Config) *string { return &c.AWSRegion }},
+	{"AWS_ROLE", func(c *Config) *string { return &c.AWSRole }},
+	{"PROMETHEUS_WORKSPACE_URL", func(c *Config) *string { return &c.PrometheusWorkspaceURL }},
+	{"GITHUB_TOKEN", func(c *Config) *string { return &c.GitHubToken }},
+	{"GITHUB_ENTERPRISE_TOKEN", func(c *Config) *string { return &c.GitHubEnterpriseToken }},
+	{"COMPASS_API_TOKEN", func(c *Config) *string { return &c.CompassAPIToken }},
+}
+
+// LoadConfig builds a config from defaults, the YAML file at path and the
+// environment. An empty path falls back to $COMPASS_COMPUTE_CONFIG and then to
+// ./compass-compute.yaml, both of which may be absent.
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("COMPASS_COMPUTE_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+	default:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg.applyEnv()
+	return &cfg, nil
+}
+
+func (c *Config) applyEnv() {
+	for _, env := range configEnv {
+		if v := os.Getenv(env.name); v != "" {
+			*env.field(c) = v
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("METRIC_STRICT")); err == nil {
+		c.StrictMetrics = v
+	}
+	applyHTTPEnv(&c.HTTP)
+}
+
+var (
+	configMu sync.RWMutex
+	// currentConfig starts out as defaults plus environment so library use works without LoadConfig
+	currentConfig = func() *Config {
+		cfg := DefaultConfig()
+		cfg.applyEnv()
+		return &cfg
+	}()
+)
+
+// SetConfig installs cfg as the configuration of this run.
+func SetConfig(cfg *Config) {
+	configMu.Lock()
+	currentConfig = cfg
+	configMu.Unlock()
+	SetHTTPConfig(cfg.HTTP)
+}
+
+// CurrentConfig returns the configuration of this run.
+func CurrentConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return currentConfig
+}
+
+func (c *Config) GraphQLEndpoint() string {
+	return strings.TrimSuffix(c.CompassBaseURL, "/") + "/graphql"
+}
+
+// Masked returns a copy of the config with every secret field obscured.
+func (c *Config) Masked() Config {
+	masked := *c
+	masked.GitHubToken = maskSecret(c.GitHubToken)
+	masked.GitHubEnterpriseToken = maskSecret(c.GitHubEnterpriseToken)
+	masked.CompassAPIToken = maskSecret(c.CompassAPIToken)
+	return masked
+}
+
+func maskSecret(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 12:
+		return "****"
+	default:
+		return "****" + secret[len(secret)-4:]
+	}
+}
+
+// GetMetricLocalPath returns where metric definitions live below basePath.
+func GetMetricLocalPath(basePath string) string {
+	cfg := CurrentConfig()
+	if cfg.MetricDir != "" {
+		return filepath.Join(basePath, "metrics")
 	}
-	return DefaultMetricLocalPath
+	return filepath.Join(basePath, cfg.CatalogRepo, cfg.MetricPath)
 }
@@ -0,0 +1,46 @@
+package services
+
+import "fmt"
+
+const (
+	BackendExec        = "exec"
+	BackendGoGit       = "go-git"
+	BackendGoGitMemory = "go-git-memory"
+	BackendArchive     = "archive"
+)
+
+// RepoFetcher materialises a repository on local disk.
+type RepoFetcher interface {
+	// Clone checks out the repository described by info into repoPath and
+	// returns the resolved commit SHA. info.Branch may hold any ref; empty
+	// selects the remote's default branch; with info.Before set, the last
+	// commit of the ref before that time is checked out instead of its tip.
+	// paths, when non-empty, lists the
+	// only paths the caller needs; fetchers may use it to skip the rest.
+	Clone(info *GitInfo, repoPath string, paths []string) (string, error)
+}
+
+// CheckoutOptions controls how repositories are fetched.
+type CheckoutOptions struct {
+	Backend  string // one of the Backend* constants, exec when empty
+	Depth    int    // history depth, 0 for full history
+	Sparse   bool   // only check out the paths requested by the caller
+	CacheDir string // persistent mirror cache, disabled when empty (exec only)
+}
+
+// NewRepoFetcher returns the fetcher selected by options.Backend.
+func NewRepoFetcher(token string, options CheckoutOptions) (RepoFetcher, error) {
+	switch options.Backend {
+	case "", BackendExec:
+		return NewGitHubCloner(token, options)
+	case BackendGoGit:
+		return NewGoGitFetcher(token, options, false), nil
+	case BackendGoGitMemory:
+		return NewGoGitFetcher(token, options, true), nil
+	case BackendArchive:
+		return NewArchiveFetcher(token, options), nil
+	default:
+		return nil, fmt.Errorf("unknown git backend '%s' (use %s, %s, %s or %s)",
+			options.Backend, BackendExec, BackendGoGit, BackendGoGitMemory, BackendArchive)
+	}
+}
@@ -1,72 +1,260 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// gitHubTokenUser is the basic auth user GitHub expects alongside a token.
+const gitHubTokenUser = "x-access-token"
+
+// GitHubCloner is the RepoFetcher that shells out to the git binary.
 type GitHubCloner struct {
-	token string
+	token   string
+	options CheckoutOptions
 }
 
 type GitInfo struct {
+	Host     string
 	Owner    string
 	Repo     string
 	Path     string
 	Branch   string
+	Before   time.Time // when set, the last commit of Branch made before it is checked out
 	IsSSH    bool
 	IsGitURL bool
 }
 
-func NewGitHubCloner(token string) *GitHubCloner {
+func NewGitHubCloner(token string, options CheckoutOptions) (*GitHubCloner, error) {
 	if token == "" {
-		fmt.Printf("GITHUB_TOKEN environment variable is not set")
-		os.Exit(1)
+		return nil, fmt.Errorf("GITHUB_TOKEN environment variable is not set")
 	}
 	if !isGitAvailable() {
-		fmt.Printf("git is not installed or not available in PATH")
-		os.Exit(1)
+		return nil, fmt.Errorf("git is not installed or not available in PATH")
+	}
+	return &GitHubCloner{token: token, options: options}, nil
+}
+
+// Clone checks out the repository described by info into repoPath and returns
+// the resolved commit SHA. info.Branch may hold any ref; empty selects the
+// remote's default branch. When sparse checkouts are enabled and paths is
+// non-empty, only those paths are materialised.
+func (gc *GitHubCloner) Clone(info *GitInfo, repoPath string, paths []string) (string, error) {
+	if info == nil || info.Owner == "" || info.Repo == "" || repoPath == "" {
+		return "", fmt.Errorf("owner, repo, and destination are required")
+	}
+
+	return gc.checkout(info, repoPath, paths)
+}
+
+// cloneURL returns the credential-free remote URL of the repository.
+func cloneURL(info *GitInfo) string {
+	host := gitHost(info)
+	if info.IsSSH {
+		return fmt.Sprintf("git@%s:%s/%s.git", host, info.Owner, info.Repo)
+	}
+	return fmt.Sprintf("https://%s/%s/%s.git", host, info.Owner, info.Repo)
+}
+
+// authEnv returns the environment that authenticates git against the host of
+// info. The token travels as an http.extraHeader set through GIT_CONFIG_*
+// variables, so it never appears in argv, remote URLs or .git/config.
+func (gc *GitHubCloner) authEnv(info *GitInfo) []string {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if info.IsSSH {
+		return env
 	}
-	return &GitHubCloner{token: token}
+	host := gitHost(info)
+	token := gc.tokenFor(host)
+	if token == "" {
+		return env
+	}
+	return append(env,
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://"+host+"/.extraheader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic "+basicAuth(gitHubTokenUser, token),
+	)
 }
 
-func (gc *GitHubCloner) Clone(owner, repo, destination string) error {
-	if owner == "" || repo == "" || destination == "" {
-		return fmt.Errorf("owner, repo, and destination are required")
+func gitHost(info *GitInfo) string {
+	if info.Host == "" {
+		return DefaultGitHost
 	}
+	return info.Host
+}
 
-	repoPath := filepath.Join(destination, repo)
-	cloneURL := fmt.Sprintf("https://%s@github.com/%s/%s.git", gc.token, owner, repo)
+// tokenFor returns the token for host; GitHub Enterprise hosts may use their own.
+func (gc *GitHubCloner) tokenFor(host string) string {
+	return tokenForHost(host, gc.token)
+}
 
+func tokenForHost(host, token string) string {
+	if host != DefaultGitHost {
+		if enterpriseToken := CurrentConfig().GitHubEnterpriseToken; enterpriseToken != "" {
+			return enterpriseToken
+		}
+	}
+	return token
+}
+
+// checkout produces a detached working tree of info.Branch at repoPath, going
+// through the mirror cache when configured, and returns the checked out commit SHA.
+func (gc *GitHubCloner) checkout(info *GitInfo, repoPath string, paths []string) (string, error) {
 	// Remove existing directory
 	if _, err := os.Stat(repoPath); !os.IsNotExist(err) {
 		if err := os.RemoveAll(repoPath); err != nil {
-			return fmt.Errorf("failed to remove existing directory: %w", err)
+			return "", fmt.Errorf("failed to remove existing directory: %w", err)
 		}
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(destination, 0755); err != nil {
-		return fmt.Errorf("failed to create destination: %w", err)
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	source := cloneURL(info)
+	env := gc.authEnv(info)
+	if gc.options.CacheDir != "" {
+		mirror, err := gc.updateMirror(source, env, info)
+		if err != nil {
+			return "", err
+		}
+		// file:// makes git honour --depth for local fetches
+		source = "file://" + mirror
+	}
+
+	ref := info.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	sparse := gc.options.Sparse && len(paths) > 0
+
+	// Fetching a single ref works for branches, tags, commit SHAs and PR refs alike
+	fetch := []string{"fetch", "--quiet"}
+	// A commit before info.Before is looked up in the full history of the ref
+	if gc.options.Depth > 0 && info.Before.IsZero() {
+		fetch = append(fetch, "--depth", strconv.Itoa(gc.options.Depth))
+	}
+	if sparse {
+		fetch = append(fetch, "--filter=blob:none")
+	}
+	fetch = append(fetch, "origin", ref)
+
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", source},
+		fetch,
+	}
+	if sparse {
+		patterns := make([]string, 0, len(paths))
+		for _, p := range paths {
+			patterns = append(patterns, "/"+strings.TrimPrefix(filepath.ToSlash(p), "/"))
+		}
+		steps = append(steps, append([]string{"sparse-checkout", "set", "--no-cone"}, patterns...))
+	}
+	for _, args := range steps {
+		if _, err := runGit(repoPath, env, args...); err != nil {
+			return "", fmt.Errorf("git %s failed for ref '%s': %w", args[0], ref, err)
+		}
+	}
+
+	target := "FETCH_HEAD"
+	if !info.Before.IsZero() {
+		before, err := runGit(repoPath, nil, "rev-list", "-1", "--before", strconv.FormatInt(info.Before.Unix(), 10), "FETCH_HEAD")
+		if err != nil {
+			return "", fmt.Errorf("failed to find commit of ref '%s' before %s: %w", ref, info.Before.Format(time.RFC3339), err)
+		}
+		if before == "" {
+			return "", fmt.Errorf("ref '%s' has no commit before %s", ref, info.Before.Format(time.RFC3339))
+		}
+		target = before
+	}
+	if _, err := runGit(repoPath, env, "checkout", "--quiet", "--detach", target); err != nil {
+		return "", fmt.Errorf("git checkout failed for ref '%s': %w", ref, err)
+	}
+
+	commit, err := runGit(repoPath, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve commit: %w", err)
+	}
+	return commit, nil
+}
+
+// updateMirror fetches the cached mirror of the repository, creating it on first use.
+func (gc *GitHubCloner) updateMirror(cloneURL string, env []string, info *GitInfo) (string, error) {
+	mirror, err := filepath.Abs(filepath.Join(gc.options.CacheDir, gitHost(info), info.Owner, info.Repo+".git"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve cache directory: %w", err)
 	}
 
-	// Clone repository
-	cmd := exec.Command("git", "clone", cloneURL, repoPath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("git clone failed: %w", err)
+	if info, err := os.Stat(mirror); err == nil && info.IsDir() {
+		// Mirrors created by older versions carry the token in their remote URL
+		_, err := runGit(mirror, nil, "remote", "set-url", "origin", cloneURL)
+		if err == nil {
+			_, err = runGit(mirror, env, "remote", "update", "--prune")
+		}
+		if err == nil {
+			return mirror, configureMirror(mirror)
+		}
+		// A broken mirror is cheaper to rebuild than to repair
+		if err := os.RemoveAll(mirror); err != nil {
+			return "", fmt.Errorf("failed to remove stale mirror: %w", err)
+		}
 	}
 
+	if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if _, err := runGit("", env, "clone", "--quiet", "--mirror", cloneURL, mirror); err != nil {
+		return "", fmt.Errorf("git mirror clone failed: %w", err)
+	}
+	return mirror, configureMirror(mirror)
+}
+
+// configureMirror lets checkouts fetch arbitrary commits and partial trees from the mirror.
+func configureMirror(mirror string) error {
+	for _, kv := range [][2]string{
+		{"uploadpack.allowAnySHA1InWant", "true"},
+		{"uploadpack.allowFilter", "true"},
+	} {
+		if _, err := runGit(mirror, nil, "config", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("failed to configure mirror: %w", err)
+		}
+	}
 	return nil
 }
 
-// SetupMetricDirectory handles METRIC_DIR environment variable
-// Returns true if catalog repo should be skipped, false otherwise
-func (gc *GitHubCloner) SetupMetricDirectory(verbose bool) (bool, error) {
-	metricDir := os.Getenv("METRIC_DIR")
+// runGit runs git in dir with env added to the process environment and
+// returns its trimmed standard output. Errors are redacted.
+func runGit(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, Redact(msg))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SetupMetricDirectory handles METRIC_DIR environment variable, placing the
+// definitions below basePath and fetching git sources through fetcher. Any
+// previous content is replaced. Returns true if catalog repo should be skipped, false otherwise
+func SetupMetricDirectory(fetcher RepoFetcher, basePath string, verbose bool) (bool, error) {
+	metricDir := CurrentConfig().MetricDir
 	if metricDir == "" {
 		if verbose {
 			fmt.Println("METRIC_DIR not set, using default catalog repository")
@@ -74,23 +262,11 @@ func (gc *GitHubCloner) SetupMetricDirectory(verbose bool) (bool, error) {
 		return false, nil // Don't skip catalog repo
 	}
 
-	targetPath := filepath.Join(LocalBasePath, "metrics")
+	targetPath := filepath.Join(basePath, "metrics")
 
-	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
-		if info, err := os.Stat(targetPath); err == nil && info.IsDir() {
-			entries, err := os.ReadDir(targetPath)
-			if err != nil {
-				return false, fmt.Errorf("failed to read target directory: %w", err)
-			}
-			if len(entries) > 0 {
-				if verbose {
-					fmt.Printf("Target directory '%s' already exists and is not empty, skipping metric directory setup\n", targetPath)
-				}
-				return true, nil // Skip catalog repo
-			}
-		} else {
-			return false, fmt.Errorf("target path '%s' exists but is not a directory", targetPath)
-		}
+	// Never trust leftovers: stale definitions would be silently evaluated
+	if err := os.RemoveAll(targetPath); err != nil {
+		return false, fmt.Errorf("failed to remove stale metric directory: %w", err)
 	}
 
 	// Check if it's a local path
@@ -98,7 +274,7 @@ func (gc *GitHubCloner) SetupMetricDirectory(verbose bool) (bool, error) {
 		if verbose {
 			fmt.Printf("Using local metric directory: %s\n", metricDir)
 		}
-		return gc.copyLocalDirectory(metricDir, targetPath, verbose)
+		return copyLocalDirectory(metricDir, targetPath, verbose)
 	}
 
 	// Check if it's a git URL
@@ -107,19 +283,26 @@ func (gc *GitHubCloner) SetupMetricDirectory(verbose bool) (bool, error) {
 		if verbose {
 			fmt.Printf("Using git metric directory: %s\n", metricDir)
 		}
-		return gc.cloneAndExtractPath(gitInfo, targetPath, verbose)
+		return cloneAndExtractPath(fetcher, gitInfo, basePath, targetPath, verbose)
 	}
 
 	return false, fmt.Errorf("invalid METRIC_DIR format: %s", metricDir)
 }
 
+// LocalMetricDir reports whether METRIC_DIR is a local directory, in which
+// case the metric definitions are read without fetching anything from git.
+func LocalMetricDir() bool {
+	metricDir := CurrentConfig().MetricDir
+	return metricDir != "" && isLocalPath(metricDir)
+}
+
 func isLocalPath(path string) bool {
 	// Check if path exists locally
 	_, err := os.Stat(path)
 	return err == nil
 }
 
-func (gc *GitHubCloner) copyLocalDirectory(src, dst string, verbose bool) (bool, error) {
+func copyLocalDirectory(src, dst string, verbose bool) (bool, error) {
 	if verbose {
 		fmt.Printf("Copying local directory from %s to %s\n", src, dst)
 	}
@@ -137,29 +320,24 @@ func (gc *GitHubCloner) copyLocalDirectory(src, dst string, verbose bool) (bool,
 	return true, nil // Skip catalog repo
 }
 
-func (gc *GitHubCloner) cloneAndExtractPath(gitInfo *GitInfo, targetPath string, verbose bool) (bool, error) {
-	tempDir := filepath.Join(LocalBasePath, "temp-"+gitInfo.Repo)
-
-	// Remove temp directory if it exists
-	if err := os.RemoveAll(tempDir); err != nil {
-		return false, fmt.Errorf("failed to remove temp directory: %w", err)
-	}
+func cloneAndExtractPath(fetcher RepoFetcher, gitInfo *GitInfo, basePath, targetPath string, verbose bool) (bool, error) {
+	tempDir := filepath.Join(basePath, "temp-"+gitInfo.Repo)
 
 	// Clone the repository
-	var cloneURL string
-	if gitInfo.IsSSH {
-		cloneURL = fmt.Sprintf("git@github.com:%s/%s.git", gitInfo.Owner, gitInfo.Repo)
-	} else {
-		cloneURL = fmt.Sprintf("https://%s@github.com/%s/%s.git", gc.token, gitInfo.Owner, gitInfo.Repo)
-	}
-
 	if verbose {
-		fmt.Printf("Cloning repository: %s\n", cloneURL)
+		fmt.Printf("Cloning repository: %s\n", cloneURL(gitInfo))
 	}
 
-	cmd := exec.Command("git", "clone", cloneURL, tempDir)
-	if err := cmd.Run(); err != nil {
-		return false, fmt.Errorf("git clone failed: %w", err)
+	var paths []string
+	if gitInfo.Path != "" {
+		paths = []string{gitInfo.Path}
+	}
+	commit, err := fetcher.Clone(gitInfo, tempDir, paths)
+	if err != nil {
+		return false, err
+	}
+	if verbose {
+		fmt.Printf("Using metric definitions from commit %s\n", commit)
 	}
 
 	// Extract the specific path
@@ -193,35 +371,38 @@ func (gc *GitHubCloner) cloneAndExtractPath(gitInfo *GitInfo, targetPath string,
 }
 
 func parseGitURL(gitURL string) (*GitInfo, error) {
-	gitInfo := &GitInfo{Branch: "main"} // Default branch
+	gitInfo := &GitInfo{} // Empty branch selects the remote's default branch
 
-	// GitHub tree URL: https://github.com/owner/repo/tree/branch/path/to/dir
-	treeRegex := regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)/tree/([^/]+)/(.+)$`)
+	// Tree URL: https://github.com/owner/repo/tree/branch/path/to/dir
+	treeRegex := regexp.MustCompile(`^https://([^/]+)/([^/]+)/([^/]+)/tree/([^/]+)(?:/(.+?))?/?$`)
 	if matches := treeRegex.FindStringSubmatch(gitURL); matches != nil {
-		gitInfo.Owner = matches[1]
-		gitInfo.Repo = matches[2]
-		gitInfo.Branch = matches[3]
-		gitInfo.Path = matches[4]
+		gitInfo.Host = matches[1]
+		gitInfo.Owner = matches[2]
+		gitInfo.Repo = matches[3]
+		gitInfo.Branch = matches[4]
+		gitInfo.Path = matches[5]
 		gitInfo.IsGitURL = true
 		return gitInfo, nil
 	}
 
 	// Git HTTPS URL with path: https://github.com/owner/repo.git/path/to/dir
-	httpsRegex := regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)\.git/(.+)$`)
+	httpsRegex := regexp.MustCompile(`^https://([^/]+)/([^/]+)/([^/]+)\.git/(.+)$`)
 	if matches := httpsRegex.FindStringSubmatch(gitURL); matches != nil {
-		gitInfo.Owner = matches[1]
-		gitInfo.Repo = matches[2]
-		gitInfo.Path = matches[3]
+		gitInfo.Host = matches[1]
+		gitInfo.Owner = matches[2]
+		gitInfo.Repo = matches[3]
+		gitInfo.Path = matches[4]
 		gitInfo.IsGitURL = true
 		return gitInfo, nil
 	}
 
 	// Git SSH URL with path: git@github.com:owner/repo.git/path/to/dir
-	sshRegex := regexp.MustCompile(`^git@github\.com:([^/]+)/([^/]+)\.git/(.+)$`)
+	sshRegex := regexp.MustCompile(`^git@([^:]+):([^/]+)/([^/]+)\.git/(.+)$`)
 	if matches := sshRegex.FindStringSubmatch(gitURL); matches != nil {
-		gitInfo.Owner = matches[1]
-		gitInfo.Repo = matches[2]
-		gitInfo.Path = matches[3]
+		gitInfo.Host = matches[1]
+		gitInfo.Owner = matches[2]
+		gitInfo.Repo = matches[3]
+		gitInfo.Path = matches[4]
 		gitInfo.IsSSH = true
 		gitInfo.IsGitURL = true
 		return gitInfo, nil
@@ -230,6 +411,30 @@ func parseGitURL(gitURL string) (*GitInfo, error) {
 	return nil, fmt.Errorf("unsupported git URL format")
 }
 
+// ParseRepositoryURL parses a repository link as found on Compass components.
+// Besides the METRIC_DIR forms it accepts plain repository URLs without a path,
+// on github.com as well as GitHub Enterprise hosts.
+func ParseRepositoryURL(repoURL string) (*GitInfo, error) {
+	repoURL = strings.TrimSpace(repoURL)
+	if gitInfo, err := parseGitURL(repoURL); err == nil {
+		return gitInfo, nil
+	}
+
+	// Plain HTTPS URL: https://github.com/owner/repo(.git)
+	httpsRegex := regexp.MustCompile(`^https://([^/]+)/([^/]+)/([^/]+?)(?:\.git)?/?$`)
+	if matches := httpsRegex.FindStringSubmatch(repoURL); matches != nil {
+		return &GitInfo{Host: matches[1], Owner: matches[2], Repo: matches[3], IsGitURL: true}, nil
+	}
+
+	// Plain SSH URL: git@github.com:owner/repo.git
+	sshRegex := regexp.MustCompile(`^git@([^:]+):([^/]+)/([^/]+?)(?:\.git)?$`)
+	if matches := sshRegex.FindStringSubmatch(repoURL); matches != nil {
+		return &GitInfo{Host: matches[1], Owner: matches[2], Repo: matches[3], IsSSH: true, IsGitURL: true}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported repository URL: %s", repoURL)
+}
+
 func copyDir(src, dst string) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// GoGitFetcher is a pure-Go RepoFetcher that needs no git binary. With
+// inMemory set the object database stays in memory and only the working tree
+// is written to disk. Sparse checkouts and the mirror cache are not supported.
+type GoGitFetcher struct {
+	token    string
+	options  CheckoutOptions
+	inMemory bool
+}
+
+func NewGoGitFetcher(token string, options CheckoutOptions, inMemory bool) *GoGitFetcher {
+	return &GoGitFetcher{token: token, options: options, inMemory: inMemory}
+}
+
+func (gf *GoGitFetcher) Clone(info *GitInfo, repoPath string, paths []string) (string, error) {
+	if info == nil || info.Owner == "" || info.Repo == "" || repoPath == "" {
+		return "", fmt.Errorf("owner, repo, and destination are required")
+	}
+
+	ref := info.Branch
+	var candidates []plumbing.ReferenceName
+	switch {
+	case ref == "":
+		candidates = []plumbing.ReferenceName{""}
+	case plumbing.IsHash(ref):
+		candidates = []plumbing.ReferenceName{""}
+	case strings.HasPrefix(ref, "refs/"):
+		candidates = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		candidates = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+
+	var lastErr error
+	for _, name := range candidates {
+		repo, err := gf.clone(info, repoPath, name, plumbing.IsHash(ref))
+		if err != nil {
+			lastErr = err
+			if errors.Is(err, plumbing.ErrReferenceNotFound) || isNoMatchingRef(err) {
+				continue
+			}
+			break
+		}
+
+		if plumbing.IsHash(ref) {
+			worktree, err := repo.Worktree()
+			if err != nil {
+				return "", fmt.Errorf("failed to open worktree: %w", err)
+			}
+			if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(ref), Force: true}); err != nil {
+				return "", fmt.Errorf("failed to check out commit '%s': %w", ref, err)
+			}
+		}
+
+		head, err := repo.Head()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve commit: %w", err)
+		}
+		if !info.Before.IsZero() {
+			return checkoutBefore(repo, head.Hash(), info.Before)
+		}
+		return head.Hash().String(), nil
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return "", fmt.Errorf("go-git clone failed for ref '%s': %w", ref, lastErr)
+}
+
+func (gf *GoGitFetcher) clone(info *GitInfo, repoPath string, name plumbing.ReferenceName, fullHistory bool) (*git.Repository, error) {
+	if err := os.RemoveAll(repoPath); err != nil {
+		return nil, fmt.Errorf("failed to remove existing directory: %w", err)
+	}
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	opts := &git.CloneOptions{
+		URL:           cloneURL(info),
+		ReferenceName: name,
+		SingleBranch:  !fullHistory,
+		NoCheckout:    fullHistory,
+	}
+	if !fullHistory && info.Before.IsZero() {
+		opts.Depth = gf.options.Depth
+	}
+	if token := tokenForHost(gitHost(info), gf.token); token != "" && !info.IsSSH {
+		opts.Auth = &githttp.BasicAuth{Username: gitHubTokenUser, Password: token}
+	}
+
+	if gf.inMemory {
+		return git.Clone(memory.NewStorage(), osfs.New(repoPath), opts)
+	}
+	return git.PlainClone(repoPath, false, opts)
+}
+
+// checkoutBefore checks out the last commit reachable from tip that was made
+// before the given time.
+func checkoutBefore(repo *git.Repository, tip plumbing.Hash, before time.Time) (string, error) {
+	commits, err := repo.Log(&git.LogOptions{From: tip, Order: git.LogOrderCommitterTime, Until: &before})
+	if err != nil {
+		return "", fmt.Errorf("failed to read history: %w", err)
+	}
+	defer commits.Close()
+	commit, err := commits.Next()
+	if errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("no commit before %s", before.Format(time.RFC3339))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read history: %w", err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: commit.Hash, Force: true}); err != nil {
+		return "", fmt.Errorf("failed to check out commit '%s': %w", commit.Hash, err)
+	}
+	return commit.Hash.String(), nil
+}
+
+func isNoMatchingRef(err error) bool {
+	var noMatch git.NoMatchingRefSpecError
+	return errors.As(err, &noMatch)
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHTTPTimeout        = 30 * time.Second
+	DefaultMaxRetries         = 4
+	DefaultRetryBaseDelay     = 500 * time.Millisecond
+	DefaultRetryMaxDelay      = 30 * time.Second
+	DefaultMaxConcurrentHost  = 8
+	DefaultRequestsPerSecHost = 10.0
+)
+
+// HTTPConfig controls retries and per-host limits for every outbound HTTP request.
+type HTTPConfig struct {
+	Timeout           time.Duration `yaml:"timeout" json:"timeout"`
+	MaxRetries        int           `yaml:"maxRetries" json:"maxRetries"`
+	RetryBaseDelay    time.Duration `yaml:"retryBaseDelay" json:"retryBaseDelay"`
+	RetryMaxDelay     time.Duration `yaml:"retryMaxDelay" json:"retryMaxDelay"`
+	MaxConcurrentHost int           `yaml:"maxConcurrentPerHost" json:"maxConcurrentPerHost"`
+	RequestsPerSecond float64       `yaml:"requestsPerSecondPerHost" json:"requestsPerSecondPerHost"`
+}
+
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		Timeout:           DefaultHTTPTimeout,
+		MaxRetries:        DefaultMaxRetries,
+		RetryBaseDelay:    DefaultRetryBaseDelay,
+		RetryMaxDelay:     DefaultRetryMaxDelay,
+		MaxConcurrentHost: DefaultMaxConcurrentHost,
+		RequestsPerSecond: DefaultRequestsPerSecHost,
+	}
+}
+
+// applyHTTPEnv applies HTTP_* environment overrides to cfg.
+func applyHTTPEnv(cfg *HTTPConfig) {
+	if v, err := time.ParseDuration(os.Getenv("HTTP_TIMEOUT")); err == nil {
+		cfg.Timeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("HTTP_MAX_RETRIES")); err == nil {
+		cfg.MaxRetries = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("HTTP_RETRY_BASE_DELAY")); err == nil {
+		cfg.RetryBaseDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("HTTP_RETRY_MAX_DELAY")); err == nil {
+		cfg.RetryMaxDelay = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("HTTP_MAX_CONCURRENT_PER_HOST")); err == nil {
+		cfg.MaxConcurrentHost = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("HTTP_REQUESTS_PER_SECOND_PER_HOST"), 64); err == nil {
+		cfg.RequestsPerSecond = v
+	}
+}
+
+var (
+	httpConfigMu sync.RWMutex
+	httpConfig   = CurrentConfig().HTTP
+
+	hostLimitersMu sync.Mutex
+	hostLimiters   = map[string]*hostLimiter{}
+)
+
+// SetHTTPConfig replaces the configuration used by clients created afterwards.
+// Per-host limiters are reset so the new limits take effect.
+func SetHTTPConfig(cfg HTTPConfig) {
+	httpConfigMu.Lock()
+	httpConfig = cfg
+	httpConfigMu.Unlock()
+
+	hostLimitersMu.Lock()
+	hostLimiters = map[string]*hostLimiter{}
+	hostLimitersMu.Unlock()
+}
+
+// MarshalYAML renders durations in their human readable form.
+func (c HTTPConfig) MarshalYAML() (interface{}, error) {
+	return struct {
+		Timeout           string  `yaml:"timeout"`
+		MaxRetries        int     `yaml:"maxRetries"`
+		RetryBaseDelay    string  `yaml:"retryBaseDelay"`
+		RetryMaxDelay     string  `yaml:"retryMaxDelay"`
+		MaxConcurrentHost int     `yaml:"maxConcurrentPerHost"`
+		RequestsPerSecond float64 `yaml:"requestsPerSecondPerHost"`
+	}{
+		Timeout:           c.Timeout.String(),
+		MaxRetries:        c.MaxRetries,
+		RetryBaseDelay:    c.RetryBaseDelay.String(),
+		RetryMaxDelay:     c.RetryMaxDelay.String(),
+		MaxConcurrentHost: c.MaxConcurrentHost,
+		RequestsPerSecond: c.RequestsPerSecond,
+	}, nil
+}
+
+func CurrentHTTPConfig() HTTPConfig {
+	httpConfigMu.RLock()
+	defer httpConfigMu.RUnlock()
+	return httpConfig
+}
+
+// NewHTTPClient returns a client whose requests are retried and rate limited
+// according to the current HTTPConfig. Limits are shared by all clients per host.
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: NewRetryTransport(http.DefaultTransport)}
+}
+
+// RetryTransport retries transient failures with exponential backoff and jitter,
+// honours Retry-After and enforces per-host concurrency and request rate limits.
+// Requests that may not be repeated safely, such as POSTs not marked with
+// WithIdempotent, are only retried when the server could not have acted on them.
+type RetryTransport struct {
+	Transport http.RoundTripper
+	Config    HTTPConfig
+	// Idempotent marks every request as safe to repeat, e.g. for query APIs
+	Idempotent bool
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent marks requests made with ctx as safe to repeat, so that
+// read-only POSTs such as GraphQL queries are retried like GETs.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func (rt *RetryTransport) idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return rt.Idempotent || marked
+}
+
+func NewRetryTransport(base http.RoundTripper) *RetryTransport {
+	return &RetryTransport{Transport: base, Config: CurrentHTTPConfig()}
+}
+
+func (rt *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limiter := limiterFor(req.URL.Host, rt.Config)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := rewindBody(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := limiter.acquire(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := rt.attempt(req)
+		limiter.release()
+
+		if attempt >= rt.Config.MaxRetries || !shouldRetry(req, resp, err, rt.idempotent(req)) {
+			return resp, err
+		}
+
+		delay := Backoff(attempt, rt.Config)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = min(after, rt.Config.RetryMaxDelay)
+				limiter.pause(delay)
+			}
+			drainAndClose(resp.Body)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (rt *RetryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if rt.Config.Timeout <= 0 {
+		return rt.Transport.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), rt.Config.Timeout)
+	resp, err := rt.Transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// shouldRetry reports whether a failed attempt is worth repeating. Requests
+// that are not idempotent are only repeated when the server turned them away
+// with 429 or the connection failed before anything was sent; after a timeout
+// or a gateway error they may have taken effect already.
+func shouldRetry(req *http.Request, resp *http.Response, err error, idempotent bool) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || req.Context().Err() != nil {
+			return false
+		}
+		if dialFailed(err) {
+			return true
+		}
+		if !idempotent {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// dialFailed reports whether err is a failure to connect, so the request was
+// never sent.
+func dialFailed(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// RetryableError is a failure of a request the server provably did not act
+// on, such as a 429 or a failed connect, so sending it again is safe even
+// for mutations.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err, or an error it wraps, is a RetryableError.
+func IsRetryable(err error) bool {
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
+
+// Backoff returns an exponentially growing delay with full jitter.
+func Backoff(attempt int, cfg HTTPConfig) time.Duration {
+	ceiling := float64(cfg.RetryBaseDelay) * math.Pow(2, float64(attempt))
+	if ceiling > float64(cfg.RetryMaxDelay) {
+		ceiling = float64(cfg.RetryMaxDelay)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func rewindBody(req *http.Request) error {
+	if req.Body == nil || req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// hostLimiter bounds concurrent requests to a host and spaces them out evenly.
+type hostLimiter struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func limiterFor(host string, cfg HTTPConfig) *hostLimiter {
+	hostLimitersMu.Lock()
+	defer hostLimitersMu.Unlock()
+
+	if l, ok := hostLimiters[host]; ok {
+		return l
+	}
+	l := &hostLimiter{}
+	if cfg.MaxConcurrentHost > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrentHost)
+	}
+	if cfg.RequestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / cfg.RequestsPerSecond)
+	}
+	hostLimiters[host] = l
+	return l
+}
+
+func (l *hostLimiter) acquire(ctx context.Context) error {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	wait := l.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	l.next = now.Add(wait + l.interval)
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	}
+}
+
+func (l *hostLimiter) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// pause holds back every request to the host, e.g. after a 429 with Retry-After.
+func (l *hostLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with status and records the
+// body of every request.
+func flakyServer(t *testing.T, status, failures int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		attempt := len(bodies)
+		mu.Unlock()
+		if attempt <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+}
+
+func testTransport() *RetryTransport {
+	return &RetryTransport{Transport: http.DefaultTransport, Config: HTTPConfig{
+		MaxRetries:     3,
+		RetryBaseDelay: time.Microsecond,
+		RetryMaxDelay:  time.Millisecond,
+	}}
+}
+
+func TestRetryTransportRewindsBody(t *testing.T) {
+	server, bodies := flakyServer(t, http.StatusServiceUnavailable, 2)
+
+	req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"value": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: testTransport()}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	got := bodies()
+	if len(got) != 3 {
+		t.Fatalf("server got %d attempts, want 3", len(got))
+	}
+	for i, body := range got {
+		if body != `{"value": 1}` {
+			t.Errorf("attempt %d sent body %q", i+1, body)
+		}
+	}
+}
+
+func TestRetryTransportRetriesPOSTOnlyWhenSafe(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		ctx      context.Context
+		attempts int
+	}{
+		{"503 may have been acted on", http.StatusServiceUnavailable, context.Background(), 1},
+		{"marked idempotent", http.StatusServiceUnavailable, WithIdempotent(context.Background()), 2},
+		{"429 was turned away", http.StatusTooManyRequests, context.Background(), 2},
+		{"400 is not transient", http.StatusBadRequest, WithIdempotent(context.Background()), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, bodies := flakyServer(t, tt.status, 1)
+
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodPost, server.URL, strings.NewReader("mutation"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&http.Client{Transport: testTransport()}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got := len(bodies()); got != tt.attempts {
+				t.Errorf("server got %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := HTTPConfig{RetryBaseDelay: 100 * time.Millisecond, RetryMaxDelay: time.Second}
+	ceilings := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, ceiling := range ceilings {
+		for range 100 {
+			if delay := Backoff(attempt, cfg); delay < 1 || delay > ceiling {
+				t.Fatalf("Backoff(%d) = %s, want between 1ns and %s", attempt, delay, ceiling)
+			}
+		}
+	}
+	if delay := Backoff(64, cfg); delay < 1 || delay > time.Second {
+		t.Errorf("Backoff(64) = %s, want at most the max delay", delay)
+	}
+	if delay := Backoff(2, HTTPConfig{}); delay != 0 {
+		t.Errorf("Backoff without delays = %s, want 0", delay)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+		ok     bool
+	}{
+		{header: "", ok: false},
+		{header: "soon", ok: false},
+		{header: "-1", ok: false},
+		{header: "0", ok: true},
+		{header: "7", min: 7 * time.Second, max: 7 * time.Second, ok: true},
+		{header: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), min: 28 * time.Second, max: 30 * time.Second, ok: true},
+		{header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), ok: true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		delay, ok := retryAfter(resp)
+		if ok != tt.ok || delay < tt.min || delay > tt.max {
+			t.Errorf("retryAfter(%q) = %s, %t, want between %s and %s, %t", tt.header, delay, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetricRegistry indexes the metric definitions of a metric directory by
+// metric name and component type. It is loaded once per run.
+type MetricRegistry struct {
+	definitions []MetricDefinition
+	index       map[metricKey]int
+}
+
+type metricKey struct {
+	name          string
+	componentType string
+}
+
+func newMetricKey(name, componentType string) metricKey {
+	return metricKey{name: name, componentType: strings.ToLower(componentType)}
+}
+
+// LoadMetricRegistry parses every definition below path. Two definitions
+// claiming the same metric name for the same component type are an error,
+// whether they live in one file or in several, and so are invalid thresholds.
+func LoadMetricRegistry(path string, options MetricParseOptions) (*MetricRegistry, error) {
+	registry := &MetricRegistry{index: make(map[metricKey]int)}
+	positions := make(map[metricKey]Position)
+	var duplicates, invalid []string
+
+	err := NewMetricsParser(path, options).walk(func(_ string, fileMetrics []MetricDefinition) {
+		for _, metric := range fileMetrics {
+			if err := metric.Spec.validateThresholds(metric.Metadata.Name); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: metric '%s': %v", metric.Pos, metric.Metadata.Name, err))
+			}
+
+			i := len(registry.definitions)
+			registry.definitions = append(registry.definitions, metric)
+
+			for _, ct := range metric.Metadata.ComponentType {
+				key := newMetricKey(metric.Metadata.Name, ct)
+				if prev, exists := positions[key]; exists {
+					duplicates = append(duplicates, fmt.Sprintf("metric '%s' for type '%s' is defined in both %s and %s",
+						key.name, key.componentType, prev, metric.Pos))
+					continue
+				}
+				positions[key] = metric.Pos
+				registry.index[key] = i
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric definitions from %s: %w", path, err)
+	}
+
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("duplicate metric definitions:\n  %s", strings.Join(duplicates, "\n  "))
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid metric thresholds:\n  %s", strings.Join(invalid, "\n  "))
+	}
+
+	return registry, nil
+}
+
+// Lookup returns the definition of metricName for componentType.
+func (r *MetricRegistry) Lookup(metricName, componentType string) (*MetricDefinition, bool) {
+	i, ok := r.index[newMetricKey(metricName, componentType)]
+	if !ok {
+		return nil, false
+	}
+	return &r.definitions[i], true
+}
+
+// LevelSource returns the definition for componentType whose thresholds submit
+// their level to levelMetric.
+func (r *MetricRegistry) LevelSource(levelMetric, componentType string) (*MetricDefinition, bool) {
+	for i, definition := range r.definitions {
+		if definition.Spec.LevelMetric() != levelMetric {
+			continue
+		}
+		for _, ct := range definition.Metadata.ComponentType {
+			if strings.EqualFold(ct, componentType) {
+				return &r.definitions[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Facts returns a copy of the facts of metricName for componentType. The
+// evaluator records results on the facts, so each evaluation needs its own copy.
+func (r *MetricRegistry) Facts(metricName, componentType string) ([]Fact, error) {
+	metric, ok := r.Lookup(metricName, componentType)
+	if !ok {
+		return nil, fmt.Errorf("no facts found for metric '%s' and type '%s'", metricName, componentType)
+	}
+	return append([]Fact(nil), metric.Spec.Facts...), nil
+}
+
+// Definitions returns every loaded definition in file order.
+func (r *MetricRegistry) Definitions() []MetricDefinition {
+	return r.definitions
+}
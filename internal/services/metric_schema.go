@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metric definition schema versions. Definitions without an apiVersion are
+// treated as v1.
+const (
+	MetricAPIVersionV1      = "v1"
+	MetricAPIVersionV2      = "v2"
+	CurrentMetricAPIVersion = MetricAPIVersionV2
+)
+
+// MetricAPIVersions lists every supported schema version, oldest first.
+var MetricAPIVersions = []string{MetricAPIVersionV1, MetricAPIVersionV2}
+
+var errUnsupportedAPIVersion = errors.New("unsupported apiVersion")
+
+// metricDefinitionV1 is the original layout, with the facts below metadata.
+type metricDefinitionV1 struct {
+	APIVersion string           `yaml:"apiVersion,omitempty"`
+	Kind       string           `yaml:"kind" schema:"required"`
+	Metadata   metricMetadataV1 `yaml:"metadata" schema:"required"`
+	Spec       metricSpecV1     `yaml:"spec"`
+}
+
+type metricMetadataV1 struct {
+	MetricMetadata `yaml:",inline"`
+	Facts          []Fact `yaml:"facts,omitempty" desc:"Facts evaluated in dependency order; the last fact with a result is the metric value"`
+}
+
+type metricSpecV1 struct {
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description"`
+	Format      MetricFormat `yaml:"format"`
+}
+
+// migrate converts a v1 definition to the current version.
+func (m metricDefinitionV1) migrate() MetricDefinition {
+	return MetricDefinition{
+		APIVersion: CurrentMetricAPIVersion,
+		Kind:       m.Kind,
+		Metadata:   m.Metadata.MetricMetadata,
+		Spec: MetricSpec{
+			Name:        m.Spec.Name,
+			Description: m.Spec.Description,
+			Format:      m.Spec.Format,
+			Facts:       m.Metadata.Facts,
+		},
+	}
+}
+
+// metricSchemas maps each version to the Go type describing its layout.
+var metricSchemas = map[string]reflect.Type{
+	MetricAPIVersionV1: reflect.TypeOf(metricDefinitionV1{}),
+	MetricAPIVersionV2: reflect.TypeOf(MetricDefinition{}),
+}
+
+// decodeMetric decodes a Metric document of any supported version, starting
+// at pos, into the current layout. With strict set, fields unknown to the
+// version are errors.
+func decodeMetric(doc *yaml.Node, apiVersion string, pos Position, strict bool) (MetricDefinition, error) {
+	if apiVersion == "" {
+		apiVersion = MetricAPIVersionV1
+	}
+	schema, ok := metricSchemas[apiVersion]
+	if !ok {
+		return MetricDefinition{}, fmt.Errorf("%s: %w '%s', expected one of %s",
+			pos, errUnsupportedAPIVersion, apiVersion, strings.Join(MetricAPIVersions, ", "))
+	}
+
+	if strict {
+		var problems []error
+		for _, field := range unknownFields(doc, schema) {
+			fieldPos := Position{File: pos.File, Line: field.Line}
+			problems = append(problems, fmt.Errorf("%s: unknown field '%s' in %s definition", fieldPos, field.Value, apiVersion))
+		}
+		if len(problems) > 0 {
+			return MetricDefinition{}, errors.Join(problems...)
+		}
+	}
+
+	var metric MetricDefinition
+	if apiVersion == MetricAPIVersionV1 {
+		var legacy metricDefinitionV1
+		if err := doc.Decode(&legacy); err != nil {
+			return MetricDefinition{}, fmt.Errorf("%s: %w", pos, err)
+		}
+		metric = legacy.migrate()
+	} else if err := doc.Decode(&metric); err != nil {
+		return MetricDefinition{}, fmt.Errorf("%s: %w", pos, err)
+	}
+
+	metric.Pos = pos
+	for i := range metric.Spec.Facts {
+		metric.Spec.Facts[i].Pos.File = pos.File
+	}
+	return metric, nil
+}
+
+// schemaField is a YAML field of a struct, with inline structs flattened.
+type schemaField struct {
+	name  string
+	typ   reflect.Type
+	field reflect.StructField
+}
+
+func yamlFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if opts == "inline" {
+			fields = append(fields, yamlFields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, schemaField{name: name, typ: f.Type, field: f})
+	}
+	return fields
+}
+
+// unknownFields returns the mapping keys below node that t does not declare.
+func unknownFields(node *yaml.Node, t reflect.Type) []*yaml.Node {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	var problems []*yaml.Node
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		known := make(map[string]reflect.Type)
+		for _, f := range yamlFields(t) {
+			known[f.name] = f.typ
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			ft, ok := known[key.Value]
+			if !ok {
+				problems = append(problems, key)
+				continue
+			}
+			problems = append(problems, unknownFields(value, ft)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, item := range node.Content {
+			problems = append(problems, unknownFields(item, t.Elem())...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			problems = append(problems, unknownFields(node.Content[i], t.Elem())...)
+		}
+	}
+	return problems
+}
+
+// MetricJSONSchema returns a JSON Schema (draft 2020-12) for metric
+// definitions of the given versions, all supported versions when none are given.
+func MetricJSONSchema(versions ...string) (map[string]interface{}, error) {
+	if len(versions) == 0 {
+		versions = MetricAPIVersions
+	}
+
+	gen := &schemaGenerator{defs: make(map[string]interface{})}
+	var variants []interface{}
+	for _, version := range versions {
+		t, ok := metricSchemas[version]
+		if !ok {
+			return nil, fmt.Errorf("%w '%s', expected one of %s",
+				errUnsupportedAPIVersion, version, strings.Join(MetricAPIVersions, ", "))
+		}
+
+		schema := gen.object(t)
+		properties := schema["properties"].(map[string]interface{})
+		properties["apiVersion"] = map[string]interface{}{"const": version}
+		properties["kind"] = map[string]interface{}{"const": "Metric"}
+
+		name := "Metric" + strings.ToUpper(version)
+		schema["title"] = fmt.Sprintf("Metric definition (%s)", version)
+		gen.defs[name] = schema
+		variants = append(variants, map[string]interface{}{"$ref": "#/$defs/" + name})
+	}
+
+	root := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "compass-compute metric definition",
+		"$defs":   gen.defs,
+	}
+	if len(variants) == 1 {
+		root["$ref"] = variants[0].(map[string]interface{})["$ref"]
+	} else {
+		root["oneOf"] = variants
+	}
+	return root, nil
+}
+
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		// Shared types are emitted once under $defs; unexported version specific types are inlined
+		if !token.IsExported(t.Name()) {
+			return g.object(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // guards against recursion
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for _, f := range yamlFields(t) {
+		prop := g.schema(f.typ)
+		if desc := f.field.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		for _, opt := range strings.Split(f.field.Tag.Get("schema"), ",") {
+			switch {
+			case opt == "required":
+				required = append(required, f.name)
+			case strings.HasPrefix(opt, "enum="):
+				prop["enum"] = strings.Split(strings.TrimPrefix(opt, "enum="), "|")
+			}
+		}
+		properties[f.name] = prop
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
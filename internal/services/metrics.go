@@ -1,6 +1,9 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -11,15 +14,33 @@ import (
 
 type MetricsParser struct {
 	basePath string
+	options  MetricParseOptions
 }
 
-func NewMetricsParser(basePath string) *MetricsParser {
-	return &MetricsParser{basePath: basePath}
+// MetricParseOptions controls how strictly metric definitions are read.
+type MetricParseOptions struct {
+	// Strict rejects fields unknown to a definition's apiVersion and reports
+	// malformed documents instead of skipping them.
+	Strict bool
+}
+
+func NewMetricsParser(basePath string, options MetricParseOptions) *MetricsParser {
+	return &MetricsParser{basePath: basePath, options: options}
 }
 
 func (mp *MetricsParser) ParseMetrics() ([]MetricDefinition, error) {
 	var metrics []MetricDefinition
+	err := mp.walk(func(path string, fileMetrics []MetricDefinition) {
+		metrics = append(metrics, fileMetrics...)
+	})
+	return metrics, err
+}
 
+// walk calls fn with the definitions of every YAML file below the base path.
+// Definitions that could be read are passed on even when a file has problems;
+// the problems of all files are returned together.
+func (mp *MetricsParser) walk(fn func(path string, fileMetrics []MetricDefinition)) error {
+	var problems []error
 	err := filepath.WalkDir(mp.basePath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
@@ -31,41 +52,69 @@ func (mp *MetricsParser) ParseMetrics() ([]MetricDefinition, error) {
 		}
 
 		fileMetrics, err := mp.parseYAMLFile(path)
+		fn(path, fileMetrics)
 		if err != nil {
-			return nil // Continue on error
+			problems = append(problems, err)
 		}
-
-		metrics = append(metrics, fileMetrics...)
 		return nil
 	})
-
-	return metrics, err
+	if err != nil {
+		return err
+	}
+	return errors.Join(problems...)
 }
 
+// parseYAMLFile decodes every document of a file. Documents of other kinds are
+// skipped. Unsupported apiVersions are always reported; syntax errors, which
+// end the file, and malformed documents only in strict mode. Positions are
+// relative to the metric directory, which is usually a throwaway copy.
 func (mp *MetricsParser) parseYAMLFile(yamlFilePath string) ([]MetricDefinition, error) {
-	content, err := os.ReadFile(yamlFilePath)
+	file, err := os.Open(yamlFilePath)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	documents := strings.Split(string(content), "---")
+	name := yamlFilePath
+	if rel, err := filepath.Rel(mp.basePath, yamlFilePath); err == nil {
+		name = rel
+	}
+
+	decoder := yaml.NewDecoder(file)
 	var metrics []MetricDefinition
+	var problems []error
+
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if !errors.Is(err, io.EOF) && mp.options.Strict {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			}
+			return metrics, errors.Join(problems...)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		pos := Position{File: name, Line: doc.Content[0].Line}
 
-	for _, doc := range documents {
-		doc = strings.TrimSpace(doc)
-		if doc == "" {
+		var header struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+		}
+		if err := doc.Decode(&header); err != nil || header.Kind != "Metric" {
 			continue
 		}
 
-		var metric MetricDefinition
-		if err := yaml.Unmarshal([]byte(doc), &metric); err != nil {
+		metric, err := decodeMetric(&doc, header.APIVersion, pos, mp.options.Strict)
+		if err != nil {
+			if mp.options.Strict || errors.Is(err, errUnsupportedAPIVersion) {
+				problems = append(problems, err)
+			}
 			continue
 		}
 
-		if metric.Kind == "Metric" && metric.Metadata.Name != "" {
+		if metric.Metadata.Name != "" {
 			metrics = append(metrics, metric)
 		}
 	}
-
-	return metrics, nil
 }
@@ -1,54 +1,261 @@
 package services
 
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 type Component struct {
-	Name    string   `json:"name"`
-	ID      string   `json:"id"`
-	Type    string   `json:"type"`
-	Metrics []Metric `json:"metrics"`
+	Name       string   `json:"name"`
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Repository *GitInfo `json:"repository,omitempty"`
+	Metrics    []Metric `json:"metrics"`
+}
+
+// ComponentFilter narrows a component search. Empty fields match everything;
+// set fields must all match.
+type ComponentFilter struct {
+	Types   []string // component types, e.g. SERVICE
+	Labels  []string // every label must be present
+	OwnerID string   // owner team ID
 }
 
 type Metric struct {
-	Name         string `json:"name"`
-	DefinitionID string `json:"definitionId"`
-	SourceID     string `json:"sourceId"`
+	Name         string       `json:"name"`
+	DefinitionID string       `json:"definitionId"`
+	SourceID     string       `json:"sourceId"`
+	Latest       *MetricValue `json:"latest,omitempty"` // last value in Compass, only set by GetComponent
+}
+
+// Team is a team components can be owned by.
+type Team struct {
+	ID   string `json:"id"` // e.g. ari:cloud:identity::team/<uuid>
+	Name string `json:"name"`
+}
+
+// MetricSubmission is a metric value to store in Compass.
+type MetricSubmission struct {
+	ComponentID        string    `json:"componentId"`
+	MetricDefinitionID string    `json:"metricDefinitionId"`
+	MetricSourceID     string    `json:"metricSourceId"`
+	Value              string    `json:"value"`
+	Timestamp          time.Time `json:"timestamp"`
+}
+
+// MetricValue is a value stored in Compass for a metric source.
+type MetricValue struct {
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CompassMetricDefinition is a metric definition as stored in Compass.
+type CompassMetricDefinition struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	BuiltIn     bool   `json:"builtIn,omitempty"`
+}
+
+// CompassScorecard is a scorecard as Compass stores it.
+type CompassScorecard struct {
+	ID             string                      `json:"id"`
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description,omitempty"`
+	Importance     string                      `json:"importance"`     // REQUIRED, RECOMMENDED or USER_DEFINED
+	ComponentTypes []string                    `json:"componentTypes"` // component type IDs, e.g. SERVICE
+	OwnerID        string                      `json:"ownerId,omitempty"`
+	Criteria       []CompassScorecardCriterion `json:"criteria"`
+}
+
+// CompassScorecardCriterion is a "has metric value" criterion of a scorecard.
+type CompassScorecardCriterion struct {
+	ID                 string  `json:"id,omitempty"`
+	Name               string  `json:"name"`
+	Weight             int     `json:"weight"`
+	MetricDefinitionID string  `json:"metricDefinitionId"`
+	Comparator         string  `json:"comparator"` // e.g. GREATER_THAN_OR_EQUAL_TO
+	ComparatorValue    float64 `json:"comparatorValue"`
 }
 
 type Fact struct {
-	ID              string      `json:"id" yaml:"id"`
-	Name            string      `json:"name ,omitempty" yaml:"name,omitempty"`
-	Type            string      `json:"type ,omitempty" yaml:"type,omitempty"`
-	Source          string      `json:"source,omitempty" yaml:"source,omitempty"`
-	Repo            string      `json:"repo,omitempty" yaml:"repo,omitempty"`
-	FilePath        string      `json:"filePath,omitempty" yaml:"filePath,omitempty"`
-	JSONPath        interface{} `json:"jsonPath,omitempty" yaml:"jsonPath,omitempty"`
-	Rule            string      `json:"rule,omitempty" yaml:"rule,omitempty"`
-	Auth            interface{} `json:"auth,omitempty" yaml:"auth,omitempty"`
-	DependsOn       []string    `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
-	Method          string      `json:"method,omitempty" yaml:"method,omitempty"`
-	URI             string      `json:"uri,omitempty" yaml:"uri,omitempty"`
-	Pattern         string      `json:"pattern,omitempty" yaml:"pattern,omitempty"`
-	SearchString    string      `json:"searchString,omitempty" yaml:"searchString,omitempty"`
-	PrometheusQuery string      `json:"prometheusQuery,omitempty" yaml:"prometheusQuery,omitempty"`
+	ID              string    `json:"id" yaml:"id" schema:"required" desc:"Identifier other facts refer to in dependsOn"`
+	Name            string    `json:"name ,omitempty" yaml:"name,omitempty" desc:"Human readable name"`
+	Type            string    `json:"type ,omitempty" yaml:"type,omitempty" schema:"required,enum=extract|validate|aggregate"`
+	Source          string    `json:"source,omitempty" yaml:"source,omitempty" schema:"enum=github|api|jsonapi|prometheus" desc:"Data source of an extract fact"`
+	Repo            string    `json:"repo,omitempty" yaml:"repo,omitempty" desc:"Repository of a github fact, usually ${Metadata.Name}"`
+	FilePath        string    `json:"filePath,omitempty" yaml:"filePath,omitempty" desc:"File read by a github fact, relative to the repository root"`
+	JSONPath        string    `json:"jsonPath,omitempty" yaml:"jsonPath,omitempty" desc:"jq expression applied by the jsonpath rule"`
+	Rule            string    `json:"rule,omitempty" yaml:"rule,omitempty" desc:"jsonpath, notempty or search for extract facts, regex_match, deps_match or unique for validate facts, instant or range for prometheus"`
+	Auth            *FactAuth `json:"auth,omitempty" yaml:"auth,omitempty" desc:"Header sent with api requests"`
+	DependsOn       []string  `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty" desc:"IDs of facts whose results this fact consumes"`
+	Method          string    `json:"method,omitempty" yaml:"method,omitempty" schema:"enum=count|sum|and|or" desc:"Aggregation method"`
+	URI             string    `json:"uri,omitempty" yaml:"uri,omitempty" desc:"URL fetched by an api fact"`
+	Pattern         string    `json:"pattern,omitempty" yaml:"pattern,omitempty" desc:"Regular expression used by regex_match"`
+	SearchString    string    `json:"searchString,omitempty" yaml:"searchString,omitempty" desc:"Text looked for by the search rule"`
+	PrometheusQuery string    `json:"prometheusQuery,omitempty" yaml:"prometheusQuery,omitempty" desc:"PromQL query of a prometheus fact"`
+
+	// Pos is where the fact is defined
+	Pos Position `json:"-" yaml:"-"`
 
 	// Runtime fields
 	Result interface{} `json:"-"`
 	Done   bool        `json:"-"`
 }
 
+// UnmarshalYAML records the line the fact starts on.
+func (f *Fact) UnmarshalYAML(node *yaml.Node) error {
+	type plain Fact
+	if err := node.Decode((*plain)(f)); err != nil {
+		return err
+	}
+	f.Pos.Line = node.Line
+	return nil
+}
+
+// FactAuth sets header to the value of the environment variable TokenVar.
+type FactAuth struct {
+	Header   string `json:"header" yaml:"header" schema:"required" desc:"Request header, e.g. Authorization"`
+	TokenVar string `json:"tokenVar" yaml:"tokenVar" schema:"required" desc:"Environment variable holding the header value"`
+}
+
+// Position locates a definition in its source file.
+type Position struct {
+	File string
+	Line int
+}
+
+func (p Position) String() string {
+	switch {
+	case p.File == "":
+		return "<unknown>"
+	case p.Line == 0:
+		return p.File
+	default:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+}
+
+// MetricDefinition is a metric in the current schema version. Definitions
+// written against older versions are migrated to it when they are parsed.
 type MetricDefinition struct {
-	APIVersion string `yaml:"apiVersion" json:"apiVersion"`
-	Kind       string `yaml:"kind" json:"kind"`
-	Metadata   struct {
-		Name          string            `yaml:"name" json:"name,omitempty"`
-		Labels        map[string]string `yaml:"labels" json:"labels,omitempty"`
-		ComponentType []string          `yaml:"componentType" json:"componentType,omitempty"`
-		Facts         []Fact            `yaml:"facts,omitempty" json:"facts,omitempty"`
-	} `yaml:"metadata"`
-	Spec struct {
-		Name        string `yaml:"name" json:"name,omitempty"`
-		Description string `yaml:"description" json:"description,omitempty"`
-		Format      struct {
-			Unit string `yaml:"unit" json:"unit,omitempty"`
-		} `yaml:"format" json:"format,omitempty"`
-	} `yaml:"spec" json:"spec,omitempty"`
+	APIVersion string         `yaml:"apiVersion" json:"apiVersion" schema:"required"`
+	Kind       string         `yaml:"kind" json:"kind" schema:"required"`
+	Metadata   MetricMetadata `yaml:"metadata" json:"metadata" schema:"required"`
+	Spec       MetricSpec     `yaml:"spec" json:"spec,omitempty"`
+
+	// Pos is where the definition document starts
+	Pos Position `yaml:"-" json:"-"`
+}
+
+type MetricMetadata struct {
+	Name          string            `yaml:"name" json:"name,omitempty" schema:"required" desc:"Name of the Compass metric definition"`
+	Labels        map[string]string `yaml:"labels" json:"labels,omitempty"`
+	ComponentType []string          `yaml:"componentType" json:"componentType,omitempty" schema:"required" desc:"Compass component types the metric applies to"`
+}
+
+type MetricSpec struct {
+	Name        string       `yaml:"name" json:"name,omitempty"`
+	Description string       `yaml:"description" json:"description,omitempty"`
+	Format      MetricFormat `yaml:"format" json:"format,omitempty"`
+	Direction   string       `yaml:"direction,omitempty" json:"direction,omitempty" schema:"enum=higher-is-better|lower-is-better" desc:"Whether a higher or lower value is an improvement, higher-is-better by default"`
+	Thresholds  *Thresholds  `yaml:"thresholds,omitempty" json:"thresholds,omitempty" desc:"Levels the metric value is graded into, e.g. gold, silver and bronze"`
+	Facts       []Fact       `yaml:"facts,omitempty" json:"facts,omitempty" desc:"Facts evaluated in dependency order; the last fact with a result is the metric value"`
+}
+
+// Metric directions.
+const (
+	DirectionHigherIsBetter = "higher-is-better"
+	DirectionLowerIsBetter  = "lower-is-better"
+)
+
+// LowerIsBetter reports whether decreasing values are improvements.
+func (s MetricSpec) LowerIsBetter() bool {
+	return s.Direction == DirectionLowerIsBetter
+}
+
+// Thresholds grade a metric value into named levels.
+type Thresholds struct {
+	Levels  []Level `yaml:"levels" json:"levels" schema:"required" desc:"Levels from best to worst; a value gets the first level whose condition it meets"`
+	Default string  `yaml:"default,omitempty" json:"default,omitempty" desc:"Level of values meeting no condition, none by default"`
+	Metric  string  `yaml:"metric,omitempty" json:"metric,omitempty" desc:"Compass metric the rank of the level is submitted to: the number of levels for the best one down to 0 for the default"`
+}
+
+// Level is a named level of a metric value.
+type Level struct {
+	Name      string `yaml:"name" json:"name" schema:"required"`
+	Condition `yaml:",inline"`
+}
+
+// DefaultLevel is the level of values meeting no threshold, unless the
+// definition names one.
+const DefaultLevel = "none"
+
+// Level returns the level of value and its rank: len(Levels) for the first
+// level down to 0 for the default.
+func (t Thresholds) Level(value float64) (string, int) {
+	for i, level := range t.Levels {
+		if level.Matches(value) {
+			return level.Name, len(t.Levels) - i
+		}
+	}
+	return t.DefaultName(), 0
+}
+
+// DefaultName is the level of values meeting no condition.
+func (t Thresholds) DefaultName() string {
+	if t.Default != "" {
+		return t.Default
+	}
+	return DefaultLevel
+}
+
+// Validate checks that levels are named once and their operators known.
+func (t Thresholds) Validate() error {
+	if len(t.Levels) == 0 {
+		return fmt.Errorf("thresholds need at least one level")
+	}
+	// The default level, named or not, has rank 0 and cannot be a listed level
+	names := map[string]bool{t.DefaultName(): true}
+	for _, level := range t.Levels {
+		if level.Name == "" {
+			return fmt.Errorf("threshold levels need a name")
+		}
+		if names[level.Name] {
+			return fmt.Errorf("level '%s' is named twice", level.Name)
+		}
+		names[level.Name] = true
+		if err := level.Condition.Validate(); err != nil {
+			return fmt.Errorf("level '%s': %w", level.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateThresholds checks the thresholds of metric, if it has any. The level
+// metric must differ from the metric itself.
+func (s MetricSpec) validateThresholds(metric string) error {
+	if s.Thresholds == nil {
+		return nil
+	}
+	if s.Thresholds.Metric == metric {
+		return fmt.Errorf("thresholds.metric must name a separate metric")
+	}
+	return s.Thresholds.Validate()
+}
+
+// LevelMetric returns the metric the level rank is submitted to, if any.
+func (s MetricSpec) LevelMetric() string {
+	if s.Thresholds == nil {
+		return ""
+	}
+	return s.Thresholds.Metric
+}
+
+type MetricFormat struct {
+	Unit      string `yaml:"unit" json:"unit,omitempty" desc:"Compass unit suffix; %, count, boolean and duration units (ms, s, min, h, d) also control how results are converted"`
+	Precision *int   `yaml:"precision,omitempty" json:"precision,omitempty" desc:"Decimals kept in submitted values; defaults to 2 for percentages and durations, 0 for counts and booleans, 6 otherwise"`
 }
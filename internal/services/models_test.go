@@ -0,0 +1,68 @@
+package services
+
+import "testing"
+
+func TestThresholdsLevel(t *testing.T) {
+	thresholds := Thresholds{
+		Levels: []Level{
+			{Name: "gold", Condition: Condition{Operator: ">=", Value: 90}},
+			{Name: "silver", Condition: Condition{Operator: ">", Value: 80}},
+			{Name: "bronze", Condition: Condition{Operator: ">=", Value: 60}},
+		},
+	}
+
+	tests := []struct {
+		value float64
+		level string
+		rank  int
+	}{
+		{value: 100, level: "gold", rank: 3},
+		// First match wins although every level's condition holds
+		{value: 90, level: "gold", rank: 3},
+		{value: 89.99, level: "silver", rank: 2},
+		// > excludes the boundary, >= includes it
+		{value: 80, level: "bronze", rank: 1},
+		{value: 60, level: "bronze", rank: 1},
+		{value: 59.9, level: DefaultLevel, rank: 0},
+	}
+	for _, tt := range tests {
+		level, rank := thresholds.Level(tt.value)
+		if level != tt.level || rank != tt.rank {
+			t.Errorf("Level(%v) = %s, %d; want %s, %d", tt.value, level, rank, tt.level, tt.rank)
+		}
+	}
+
+	thresholds.Default = "failing"
+	if level, rank := thresholds.Level(0); level != "failing" || rank != 0 {
+		t.Errorf("Level(0) with a default = %s, %d; want failing, 0", level, rank)
+	}
+}
+
+func TestThresholdsValidate(t *testing.T) {
+	level := func(name, operator string) Level {
+		return Level{Name: name, Condition: Condition{Operator: operator, Value: 1}}
+	}
+
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		valid      bool
+	}{
+		{name: "valid", thresholds: Thresholds{Levels: []Level{level("good", ">=")}}, valid: true},
+		{name: "no levels", thresholds: Thresholds{}},
+		{name: "unnamed level", thresholds: Thresholds{Levels: []Level{level("", ">=")}}},
+		{name: "unknown operator", thresholds: Thresholds{Levels: []Level{level("good", "=>")}}},
+		{name: "repeated name", thresholds: Thresholds{Levels: []Level{level("good", ">="), level("good", "<")}}},
+		{name: "named like the implicit default", thresholds: Thresholds{Levels: []Level{level(DefaultLevel, ">=")}}},
+		{name: "named like the default", thresholds: Thresholds{Default: "bad", Levels: []Level{level("bad", "<")}}},
+		{name: "none with another default", thresholds: Thresholds{Default: "bad", Levels: []Level{level(DefaultLevel, ">=")}}, valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.thresholds.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -28,9 +28,10 @@ type PrometheusClient struct {
 
 func NewPrometheusClient() PrometheusClientInterface {
 	ctx := context.Background()
-	region := os.Getenv("AWS_REGION")
-	workspaceURL := os.Getenv("PROMETHEUS_WORKSPACE_URL")
-	awsRole := os.Getenv("AWS_ROLE")
+	cfg := CurrentConfig()
+	region := cfg.AWSRegion
+	workspaceURL := cfg.PrometheusWorkspaceURL
+	awsRole := cfg.AWSRole
 
 	if workspaceURL == "" {
 		panic("Prometheus workspace URL not configured")
@@ -45,15 +46,16 @@ func NewPrometheusClient() PrometheusClientInterface {
 	// Set up credentials provider
 	credProvider := getCredentialsProvider(ctx, awsCfg, awsRole)
 
-	// Create authenticated HTTP client
-	httpClient := &http.Client{
-		Transport: &SigV4RoundTripper{
-			Transport:   http.DefaultTransport,
-			Region:      region,
-			Service:     "aps",
-			Credentials: credProvider,
-		},
-	}
+	// Create authenticated HTTP client; retries wrap signing so every attempt is freshly signed
+	transport := NewRetryTransport(&SigV4RoundTripper{
+		Transport:   http.DefaultTransport,
+		Region:      region,
+		Service:     "aps",
+		Credentials: credProvider,
+	})
+	// Queries are sent as POSTs but only read
+	transport.Idempotent = true
+	httpClient := &http.Client{Transport: transport}
 
 	// Initialize Prometheus client
 	promClient, err := api.NewClient(api.Config{
@@ -109,7 +111,8 @@ func (pc *PrometheusClient) QueryRange(query string, r v1.Range) (model.Value, e
 }
 
 type PrometheusServiceInterface interface {
-	InstantQuery(queryString string) (float64, error)
+	// InstantQuery evaluates the query at the given time, now when zero.
+	InstantQuery(queryString string, at time.Time) (float64, error)
 	RangeQuery(queryString string, start, end time.Time, step time.Duration) (model.Value, error)
 }
 
@@ -121,8 +124,11 @@ func NewPrometheusService(client PrometheusClientInterface) *PrometheusService {
 	return &PrometheusService{client: client}
 }
 
-func (ps *PrometheusService) InstantQuery(queryString string) (float64, error) {
-	return ps.client.Query(queryString, time.Now())
+func (ps *PrometheusService) InstantQuery(queryString string, at time.Time) (float64, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return ps.client.Query(queryString, at)
 }
 func (ps *PrometheusService) RangeQuery(queryString string, start, end time.Time, step time.Duration) (model.Value, error) {
 	r := v1.Range{
@@ -132,3 +138,29 @@ func (ps *PrometheusService) RangeQuery(queryString string, start, end time.Time
 	}
 	return ps.client.QueryRange(queryString, r)
 }
+
+// lazyPrometheusService connects to Prometheus on the first query, so runs
+// without Prometheus facts need no AWS setup.
+type lazyPrometheusService struct {
+	once    sync.Once
+	service PrometheusServiceInterface
+}
+
+func NewLazyPrometheusService() PrometheusServiceInterface {
+	return &lazyPrometheusService{}
+}
+
+func (l *lazyPrometheusService) get() PrometheusServiceInterface {
+	l.once.Do(func() {
+		l.service = NewPrometheusService(NewPrometheusClient())
+	})
+	return l.service
+}
+
+func (l *lazyPrometheusService) InstantQuery(queryString string, at time.Time) (float64, error) {
+	return l.get().InstantQuery(queryString, at)
+}
+
+func (l *lazyPrometheusService) RangeQuery(queryString string, start, end time.Time, step time.Duration) (model.Value, error) {
+	return l.get().RangeQuery(queryString, start, end, step)
+}
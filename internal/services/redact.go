@@ -0,0 +1,40 @@
+package services
+
+import (
+	"encoding/base64"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var urlCredentials = regexp.MustCompile(`(://)[^/@\s]+@`)
+
+// Redact masks configured tokens, their basic auth encodings and any
+// credentials embedded in URLs so s is safe to print.
+func Redact(s string) string {
+	cfg := CurrentConfig()
+	for _, secret := range []string{cfg.GitHubToken, cfg.GitHubEnterpriseToken, cfg.CompassAPIToken} {
+		if len(secret) < 4 {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "****")
+		s = strings.ReplaceAll(s, basicAuth(gitHubTokenUser, secret), "****")
+	}
+	return urlCredentials.ReplaceAllString(s, "${1}****@")
+}
+
+// RedactingWriter redacts everything written through it, e.g. for the log package.
+type RedactingWriter struct {
+	W io.Writer
+}
+
+func (rw RedactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.W, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func basicAuth(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
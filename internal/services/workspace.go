@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Workspace is the run-scoped directory holding repository checkouts and
+// metric definitions. Each run gets a fresh directory so nothing from a
+// previous run is ever reused.
+type Workspace struct {
+	Root string
+	keep bool
+
+	once sync.Once
+	err  error
+}
+
+// NewWorkspace creates a fresh run directory inside root, or inside the system
+// temp directory when root is empty. With keep set, Cleanup leaves it in place.
+func NewWorkspace(root string, keep bool) (*Workspace, error) {
+	if root != "" {
+		if err := os.MkdirAll(root, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create workspace root: %w", err)
+		}
+	}
+
+	dir, err := os.MkdirTemp(root, "compass-compute-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return &Workspace{Root: dir, keep: keep}, nil
+}
+
+// Path joins elem onto the workspace root.
+func (w *Workspace) Path(elem ...string) string {
+	return filepath.Join(append([]string{w.Root}, elem...)...)
+}
+
+// Cleanup removes the workspace unless it is kept. It is safe to call more than once.
+func (w *Workspace) Cleanup() error {
+	w.once.Do(func() {
+		if w.keep {
+			return
+		}
+		if err := os.RemoveAll(w.Root); err != nil {
+			w.err = fmt.Errorf("failed to remove workspace %s: %w", w.Root, err)
+		}
+	})
+	return w.err
+}
+
+// CleanupOnInterrupt removes the workspace when the process is interrupted or
+// terminated. The returned function stops watching for signals.
+func (w *Workspace) CleanupOnInterrupt() func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			if err := w.Cleanup(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}